@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"codingtask/simulation"
+)
+
+func analyzeCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("analyze", "[--map <file>]", stderr)
+	mf := addMapFlags(fs)
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	if err := mf.validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	input, mapName, err := openMap(mf.path, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer input.Close()
+
+	stats, err := simulation.AnalyzeMap(input, mf.options(mapName)...)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", mapErrorPosition(mapName, err), err)
+		return exitParseError
+	}
+
+	fmt.Fprintf(stdout, "cities:             %d\n", stats.Cities)
+	fmt.Fprintf(stdout, "roads:              %d\n", stats.Roads)
+	fmt.Fprintf(stdout, "one-way roads:      %d\n", stats.OneWayRoads)
+	fmt.Fprintf(stdout, "isolated cities:    %d\n", stats.IsolatedCities)
+	fmt.Fprintf(stdout, "components:         %d\n", stats.Components)
+	fmt.Fprintf(stdout, "largest component:  %d\n", stats.LargestComponent)
+	fmt.Fprintf(stdout, "max roads per city: %d\n", stats.MaxRoads)
+
+	return exitOK
+}
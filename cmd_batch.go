@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/exp/slices"
+
+	"codingtask/simulation"
+)
+
+// batchRun is the outcome of a single simulation of a batch
+type batchRun struct {
+	seed            int64
+	battleRule      string
+	state           simulation.SimState
+	iterations      int
+	destroyedCities int
+	aliveAliens     int
+}
+
+func batchCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("batch", "[--aliens <n>] [--runs <n>] [--battle-rule <rule>,...] [--map <file>] [flags]", stderr)
+	mf := addMapFlags(fs)
+	sf := addSimFlags(fs)
+	runs := fs.Int("runs", 10, "number of seeds to run, simulation i uses seed+i as seed. Each seed is run once per battle rule")
+	outputFormat := fs.String("output-format", "text", "output format, one of: text, csv")
+	lf := addLogFlags(fs)
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	if err := mf.validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	logger, err := lf.newLogger(stderr)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	if err := sf.validate(fs); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	if *runs < 1 {
+		return usageError(fs, "number of runs must be greater than 0")
+	}
+
+	if *outputFormat != "text" && *outputFormat != "csv" {
+		return usageError(fs, "unknown output format: %s", *outputFormat)
+	}
+
+	// Report the seed so the runs can be replayed with --seed
+	if !isFlagSet(fs, "seed") {
+		fmt.Fprintf(stderr, "using seed %d\n", *sf.seed)
+	}
+
+	input, mapName, err := openMap(mf.path, stdin)
+	if err != nil {
+		logger.Error("failed to read map", "err", err)
+		return exitError
+	}
+	defer input.Close()
+
+	// the map is parsed again for every run, keep it in memory
+	data, err := io.ReadAll(input)
+	if err != nil {
+		logger.Error("failed to read map", "map", mapName, "err", err)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	total := *runs * len(sf.battleRules)
+	results := make([]batchRun, 0, total)
+runs:
+	for i := 0; i < *runs; i++ {
+		seed := *sf.seed + int64(i)
+		for _, rule := range sf.battleRules {
+			runLogger := logger.With("seed", seed)
+			if len(sf.battleRules) > 1 {
+				runLogger = runLogger.With("battle_rule", rule)
+			}
+
+			opts := append(sf.options(seed, runLogger), simulation.WithBattleRule(rule))
+			sim, err := simulation.NewSimulation(bytes.NewReader(data), *sf.aliens, append(opts, mf.options(mapName)...)...)
+			if err != nil {
+				logger.Error("failed to create simulation", "pos", mapErrorPosition(mapName, err), "err", err)
+				return exitParseError
+			}
+
+			state, err := sim.RunContext(ctx)
+			if err != nil {
+				runLogger.Error("failed to run simulation", "err", err)
+				return exitError
+			}
+			if state == simulation.SimStateInterrupted {
+				break runs
+			}
+
+			result := sim.Result()
+			run := batchRun{
+				seed:            seed,
+				battleRule:      rule,
+				state:           state,
+				iterations:      result.Iterations,
+				destroyedCities: len(result.DestroyedCities),
+			}
+			for _, a := range result.Aliens {
+				if a.Fate != simulation.FateDead {
+					run.aliveAliens++
+				}
+			}
+			results = append(results, run)
+
+			runLogger.Debug("simulation ended", "state", state)
+		}
+	}
+
+	if *outputFormat == "csv" {
+		err = writeBatchCSV(stdout, results)
+	} else {
+		err = writeBatchText(stdout, results, len(sf.battleRules) > 1)
+	}
+	if err != nil {
+		logger.Error("failed to write result", "err", err)
+		return exitError
+	}
+
+	if len(results) < total {
+		logger.Warn("batch interrupted", "completed", len(results), "runs", total)
+		return exitInterrupted
+	}
+
+	return exitOK
+}
+
+// writeBatchText writes one line per run followed by the number of runs per
+// end state. With several battle rules each line names the rule, and the runs
+// are counted per rule and end state
+func writeBatchText(w io.Writer, results []batchRun, compareRules bool) error {
+	type group struct {
+		battleRule string
+		state      simulation.SimState
+	}
+	counts := make(map[group]int)
+	var groups []group
+	for _, r := range results {
+		g := group{state: r.state}
+		run := fmt.Sprintf("seed %d", r.seed)
+		if compareRules {
+			g.battleRule = r.battleRule
+			run += ", " + r.battleRule
+		}
+		if counts[g] == 0 {
+			groups = append(groups, g)
+		}
+		counts[g]++
+
+		_, err := fmt.Fprintf(
+			w,
+			"%s: %s after %d iterations, %d cities destroyed, %d aliens alive\n",
+			run, r.state, r.iterations, r.destroyedCities, r.aliveAliens,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "\n%d runs\n", len(results)); err != nil {
+		return err
+	}
+	if compareRules {
+		// group the counts of each rule
+		slices.SortStableFunc(groups, func(a, b group) bool { return a.battleRule < b.battleRule })
+	}
+	for _, g := range groups {
+		label := string(g.state)
+		if compareRules {
+			label = g.battleRule + " " + label
+		}
+		if _, err := fmt.Fprintf(w, "%s: %d\n", label, counts[g]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeBatchCSV writes a header row followed by one row per run
+func writeBatchCSV(w io.Writer, results []batchRun) error {
+	records := [][]string{{"seed", "state", "iterations", "destroyed_cities", "alive_aliens", "battle_rule"}}
+	for _, r := range results {
+		records = append(records, []string{
+			strconv.FormatInt(r.seed, 10),
+			string(r.state),
+			strconv.Itoa(r.iterations),
+			strconv.Itoa(r.destroyedCities),
+			strconv.Itoa(r.aliveAliens),
+			r.battleRule,
+		})
+	}
+
+	return csv.NewWriter(w).WriteAll(records)
+}
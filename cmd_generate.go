@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"codingtask/mapgen"
+)
+
+func generateCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("generate", "[--topology <topology>] [--width <n>] [--height <n>] [--seed <n>] [--out <file>]", stderr)
+	topology := fs.String("topology", string(mapgen.TopologyGrid), "shape of the map, one of: "+strings.Join(mapgen.Topologies(), ", "))
+	width := fs.Int("width", 10, "number of columns of the grid")
+	height := fs.Int("height", 10, "number of rows of the grid")
+	removal := fs.Float64("removal", 0, "fraction of roads to remove randomly, from 0 to less than 1")
+	extraRoads := fs.Int("extra-roads", 0, "number of roads added to the spanning tree of the tree topology")
+	isolated := fs.Int("isolated", 0, "number of cities without roads to add")
+	seed := fs.Int64("seed", 0, "seed for the random source, a random seed is used when not set")
+	out := fs.String("out", "", "file to write the map to, the map is written to stdout when not set or set to -")
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	cfg := mapgen.Config{
+		Topology:   mapgen.Topology(*topology),
+		Width:      *width,
+		Height:     *height,
+		Removal:    *removal,
+		ExtraRoads: *extraRoads,
+		Isolated:   *isolated,
+		Seed:       *seed,
+	}
+	if err := cfg.Validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	// Report the seed so the map can be generated again with --seed
+	if cfg.IsRandom() && !isFlagSet(fs, "seed") {
+		cfg.Seed = time.Now().UnixNano()
+		fmt.Fprintf(stderr, "using seed %d\n", cfg.Seed)
+	}
+
+	w := stdout
+	if *out != "" && *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to create map file: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := mapgen.Generate(w, cfg); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return exitOK
+}
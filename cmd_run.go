@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+
+	"codingtask/simulation"
+)
+
+// stateExitCodes maps the end states of a simulation to the exit code of the
+// run command
+var stateExitCodes = map[simulation.SimState]int{
+	simulation.SimStateAllAliensDeadOrTrapped:  exitAllAliensDeadOrTrapped,
+	simulation.SimStateOnlyOneAlienLeft:        exitOnlyOneAlienLeft,
+	simulation.SimStateAliveAliensDisconnected: exitAliveAliensDisconnected,
+	simulation.SimStateTooFewAliensLeft:        exitTooFewAliensLeft,
+	simulation.SimStateMaxIterationsReached:    exitMaxIterationsReached,
+	simulation.SimStateInterrupted:             exitInterrupted,
+}
+
+// simFlags are the flags configuring a simulation, shared by the run and
+// batch commands
+type simFlags struct {
+	aliens        *int
+	seed          *int64
+	maxIterations *int
+	maxMoves      *int
+	strategy      *string
+	// alienStrategies and alienStrengths contain the movement strategies and
+	// strengths set for single aliens
+	alienStrategies *alienValues[string]
+	alienStrengths  *alienValues[float64]
+	battleRule      *string
+	minCombatants   *int
+	// battleRules contains the battle rules of --battle-rule, set by validate
+	battleRules []string
+	// placement, placementAttribute, placementCenter and placementRadius
+	// make up the placement config, see placementConfig
+	placement          *string
+	placementAttribute *string
+	placementCenter    *string
+	placementRadius    *int
+	simultaneous       *bool
+	crossing           *string
+}
+
+func addSimFlags(fs *flag.FlagSet) *simFlags {
+	f := &simFlags{
+		aliens:        fs.Int("aliens", 0, "number of aliens to place in the world, must be greater than 1. Defaults to the @aliens of the map"),
+		seed:          fs.Int64("seed", 0, "seed for the random source, a random seed is used when not set"),
+		maxIterations: fs.Int("max-iterations", 0, "maximum number of iterations to run, 0 means no limit"),
+		maxMoves:      fs.Int("max-moves", simulation.DefaultMaxMoves, "maximum number of moves per alien, staying in a city counts as a move, 0 means no limit"),
+		strategy: fs.String(
+			"strategy",
+			simulation.DefaultMovementStrategy,
+			"movement strategy of the aliens, one of: "+strings.Join(simulation.MovementStrategies(), ", "),
+		),
+		alienStrategies: &alienValues[string]{values: map[int]string{}, parse: parseStrategy},
+		alienStrengths:  &alienValues[float64]{values: map[int]float64{}, parse: parseStrength},
+		battleRule: fs.String(
+			"battle-rule",
+			simulation.DefaultBattleRule,
+			"rule deciding the outcome of battles, one of: "+strings.Join(simulation.BattleRules(), ", ")+". batch accepts a comma-separated list to compare rules on the same seeds",
+		),
+		minCombatants: fs.Int("min-combatants", simulation.DefaultMinCombatants, "number of aliens in a city needed for a battle, at least 2"),
+		placement: fs.String(
+			"placement",
+			string(simulation.PlacementUniform),
+			"how aliens not placed by the map or --placement-file are placed, one of: "+strings.Join(simulation.PlacementModes(), ", "),
+		),
+		placementAttribute: fs.String("placement-attribute", "", "numeric city attribute of a JSON map weighting the cities for --placement weighted"),
+		placementCenter:    fs.String("placement-center", "", "city the aliens are placed around for --placement clustered"),
+		placementRadius:    fs.Int("placement-radius", 1, "maximum number of roads between --placement-center and the cities aliens are placed in"),
+		simultaneous:       fs.Bool("simultaneous", false, "let all aliens pick their move before any alien moves, instead of one after another"),
+		crossing: fs.String(
+			"crossing",
+			string(simulation.CrossingBattle),
+			"what happens to aliens crossing each other on a road with --simultaneous, one of: "+strings.Join(simulation.CrossingOutcomes(), ", "),
+		),
+	}
+	fs.Var(f.alienStrategies, "alien-strategy", "movement strategy of a single alien as `<alien>=<strategy>`, overriding --strategy. Can be repeated")
+	fs.Var(f.alienStrengths, "alien-strength", "strength of a single alien in battles as `<alien>=<strength>`, aliens have strength 1 by default. Can be repeated")
+	return f
+}
+
+// alienValues is a repeatable flag setting a value for single aliens, given
+// as <alien>=<value>
+type alienValues[T any] struct {
+	values map[int]T
+	// parse parses and validates a value
+	parse func(string) (T, error)
+}
+
+func (a *alienValues[T]) String() string {
+	aliens := maps.Keys(a.values)
+	slices.Sort(aliens)
+
+	pairs := make([]string, len(aliens))
+	for i, alien := range aliens {
+		pairs[i] = fmt.Sprintf("%d=%v", alien, a.values[alien])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (a *alienValues[T]) Set(value string) error {
+	alien, raw, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("expected <alien>=<value>, got '%s'", value)
+	}
+
+	n, err := strconv.Atoi(alien)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid alien '%s', aliens are numbered from 1", alien)
+	}
+
+	v, err := a.parse(raw)
+	if err != nil {
+		return err
+	}
+
+	a.values[n] = v
+	return nil
+}
+
+// parseStrategy returns the name of a registered movement strategy
+func parseStrategy(name string) (string, error) {
+	if !slices.Contains(simulation.MovementStrategies(), name) {
+		return "", fmt.Errorf("unknown movement strategy: %s", name)
+	}
+	return name, nil
+}
+
+// parseStrength returns a strength greater than 0
+func parseStrength(value string) (float64, error) {
+	strength, err := strconv.ParseFloat(value, 64)
+	if err != nil || strength <= 0 {
+		return 0, fmt.Errorf("invalid strength '%s', must be a number greater than 0", value)
+	}
+	return strength, nil
+}
+
+// validate returns an error if the flags are invalid, and sets a random seed
+// if no seed was given
+func (f *simFlags) validate(fs *flag.FlagSet) error {
+	if !isFlagSet(fs, "aliens") {
+		*f.aliens = simulation.MapAliens
+	} else if *f.aliens < 2 {
+		return fmt.Errorf("number of aliens must be greater than 1")
+	}
+
+	if _, err := parseStrategy(*f.strategy); err != nil {
+		return err
+	}
+
+	f.battleRules = strings.Split(*f.battleRule, ",")
+	for _, rule := range f.battleRules {
+		if !slices.Contains(simulation.BattleRules(), rule) {
+			return fmt.Errorf("unknown battle rule: %s", rule)
+		}
+	}
+
+	if *f.minCombatants < 2 {
+		return fmt.Errorf("minimum number of combatants must be at least 2")
+	}
+
+	if err := f.placementConfig().Validate(); err != nil {
+		return err
+	}
+
+	if !slices.Contains(simulation.CrossingOutcomes(), *f.crossing) {
+		return fmt.Errorf("unknown crossing outcome: %s", *f.crossing)
+	}
+	if isFlagSet(fs, "crossing") && !*f.simultaneous {
+		return fmt.Errorf("--crossing requires --simultaneous")
+	}
+
+	if !isFlagSet(fs, "seed") {
+		*f.seed = time.Now().UnixNano()
+	}
+
+	return nil
+}
+
+// placementConfig returns the placement config set by the placement flags
+func (f *simFlags) placementConfig() simulation.PlacementConfig {
+	return simulation.PlacementConfig{
+		Mode:      simulation.PlacementMode(*f.placement),
+		Attribute: *f.placementAttribute,
+		Center:    *f.placementCenter,
+		Radius:    *f.placementRadius,
+	}
+}
+
+// options returns the simulation options for the given seed, with the first
+// battle rule
+func (f *simFlags) options(seed int64, logger *slog.Logger) []simulation.Option {
+	opts := []simulation.Option{
+		simulation.WithMaxIterations(*f.maxIterations),
+		simulation.WithMaxMoves(*f.maxMoves),
+		simulation.WithSeed(seed),
+		simulation.WithLogger(logger),
+		simulation.WithMovementStrategy(*f.strategy),
+		simulation.WithBattleRule(f.battleRules[0]),
+		simulation.WithMinCombatants(*f.minCombatants),
+		simulation.WithPlacement(f.placementConfig()),
+	}
+	if *f.simultaneous {
+		opts = append(opts, simulation.WithSimultaneousMoves(simulation.CrossingOutcome(*f.crossing)))
+	}
+	for alien, strategy := range f.alienStrategies.values {
+		opts = append(opts, simulation.WithAlienMovementStrategy(alien, strategy))
+	}
+	for alien, strength := range f.alienStrengths.values {
+		opts = append(opts, simulation.WithAlienStrength(alien, strength))
+	}
+	return opts
+}
+
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("run", "[--aliens <n>] [--map <file>] [flags]", stderr)
+	mf := addMapFlags(fs)
+	sf := addSimFlags(fs)
+	rf := addRepairFlags(fs)
+	outputFormat := fs.String("output-format", "text", "output format, one of: text, json, csv, json-map")
+	dotPath := fs.String("dot", "", "write the map as a Graphviz DOT graph to the file after the run")
+	dotView := fs.String("dot-view", string(simulation.DOTFinal), "map written by --dot, one of: initial, final, overlay")
+	lf := addLogFlags(fs)
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	if err := mf.validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	if err := rf.validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	logger, err := lf.newLogger(stderr)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	if err := sf.validate(fs); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if len(sf.battleRules) > 1 {
+		return usageError(fs, "run takes a single battle rule, use batch to compare rules")
+	}
+
+	switch *outputFormat {
+	case "text", "json", "csv", "json-map":
+	default:
+		return usageError(fs, "unknown output format: %s", *outputFormat)
+	}
+
+	switch simulation.DOTView(*dotView) {
+	case simulation.DOTInitial, simulation.DOTFinal, simulation.DOTOverlay:
+	default:
+		return usageError(fs, "unknown DOT view: %s", *dotView)
+	}
+
+	// Report the seed so the run can be replayed with --seed, regardless of
+	// the log level
+	if !isFlagSet(fs, "seed") {
+		fmt.Fprintf(stderr, "using seed %d\n", *sf.seed)
+	}
+
+	input, mapName, err := openMap(mf.path, stdin)
+	if err != nil {
+		logger.Error("failed to read map", "err", err)
+		return exitError
+	}
+	defer input.Close()
+
+	opts := append(sf.options(*sf.seed, logger), mf.options(mapName)...)
+	if *outputFormat == "text" {
+		// Announce destroyed cities on STDOUT, diagnostics go to STDERR. The
+		// structured formats contain the destroyed cities instead
+		opts = append(opts, simulation.WithAnnouncements(stdout))
+	}
+	if rf.repair {
+		opts = append(opts, simulation.WithRepair())
+	}
+
+	sim, err := simulation.NewSimulation(input, *sf.aliens, opts...)
+	if err != nil {
+		logger.Error("failed to create simulation", "pos", mapErrorPosition(mapName, err), "err", err)
+		return exitParseError
+	}
+
+	// Report the repairs on STDERR to keep STDOUT clean, and write the
+	// repaired map before any city is destroyed
+	for _, r := range sim.Repairs() {
+		logger.Warn("repaired map", "repair", r.String())
+	}
+	if rf.repairedMap != "" {
+		if err := os.WriteFile(rf.repairedMap, []byte(sim.CitiesToString(sim.SurvivedCities())), 0o644); err != nil {
+			logger.Error("failed to write repaired map", "err", err)
+			return exitError
+		}
+	}
+
+	sim.Subscribe(simulation.ObserverFunc(func(e simulation.Event) {
+		logEvent(logger, e)
+	}))
+
+	// Run simulation, stop cleanly on SIGINT or SIGTERM and still print the
+	// world as it stood
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	state, err := sim.RunContext(ctx)
+	if err != nil {
+		logger.Error("failed to run simulation", "err", err)
+		return exitError
+	}
+
+	logger.Info("simulation ended", "state", state, "seed", sim.Seed())
+
+	if *dotPath != "" {
+		if err := writeDOT(*dotPath, sim, simulation.DOTView(*dotView)); err != nil {
+			logger.Error("failed to write DOT graph", "err", err)
+			return exitError
+		}
+	}
+
+	// Print simulation result
+	switch *outputFormat {
+	case "json":
+		err = sim.WriteJSON(stdout)
+	case "csv":
+		err = sim.WriteCSV(stdout)
+	case "json-map":
+		err = sim.WriteMapJSON(stdout, sim.SurvivedCities())
+	default:
+		_, err = fmt.Fprintln(stdout, sim.CitiesToString(sim.SurvivedCities()))
+	}
+	if err != nil {
+		logger.Error("failed to write result", "err", err)
+		return exitError
+	}
+
+	return stateExitCodes[state]
+}
+
+// writeDOT writes the map of the simulation as a DOT graph to the file at path
+func writeDOT(path string, sim *simulation.Simulation, view simulation.DOTView) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := sim.WriteDOT(f, view); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// logEvent logs the events emitted by the simulation
+func logEvent(logger *slog.Logger, e simulation.Event) {
+	switch e := e.(type) {
+	case simulation.AlienMoved:
+		logger.Debug("alien moved", "iteration", e.Iteration, "alien", e.Alien, "from", e.From, "to", e.To)
+	case simulation.AliensCrossed:
+		logger.Info("aliens crossed", "iteration", e.Iteration, "cities", e.Cities, "aliens", e.Aliens, "survivors", e.Survivors, "outcome", e.Outcome)
+	case simulation.CityDestroyed:
+		logger.Info("city destroyed", "iteration", e.Iteration, "city", e.City, "aliens", e.Aliens)
+	case simulation.AlienTrapped:
+		logger.Info("alien trapped", "iteration", e.Iteration, "alien", e.Alien, "city", e.City)
+	}
+}
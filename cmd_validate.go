@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"codingtask/simulation"
+)
+
+func validateCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := newFlagSet("validate", "[--map <file>] [--repair [--repaired-map <file>]]", stderr)
+	mf := addMapFlags(fs)
+	rf := addRepairFlags(fs)
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	if err := mf.validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	if err := rf.validate(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	input, mapName, err := openMap(mf.path, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	defer input.Close()
+
+	var (
+		diagnostics []simulation.Diagnostic
+		repairs     []simulation.RoadRepair
+		repaired    *bytes.Buffer
+	)
+	if rf.repair {
+		// RepairMap only writes the map if output is not nil
+		var output io.Writer
+		if rf.repairedMap != "" {
+			repaired = &bytes.Buffer{}
+			output = repaired
+		}
+		repairs, diagnostics, err = simulation.RepairMap(input, output, mf.options(mapName)...)
+	} else {
+		diagnostics, err = simulation.ValidateMap(input, mf.options(mapName)...)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", mapName, err)
+		return exitError
+	}
+
+	// print the repairs and diagnostics compiler-style, e.g.
+	// map.txt:3:5: repair: added road 'south=Foo' to city 'Bar'
+	// map.txt:12:5: error: invalid direction 'up' for city 'Foo'
+	for _, r := range repairs {
+		fmt.Fprintln(stdout, r)
+	}
+
+	errors, warnings := 0, 0
+	for _, d := range diagnostics {
+		fmt.Fprintln(stdout, d)
+		if d.Severity == simulation.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+	}
+
+	summary := "ok"
+	if len(diagnostics) > 0 {
+		summary = fmt.Sprintf("%s, %s", plural(errors, "error"), plural(warnings, "warning"))
+	}
+	if rf.repair {
+		summary += ", " + plural(len(repairs), "repair")
+	}
+	fmt.Fprintf(stdout, "%s: %s\n", mapName, summary)
+
+	if errors > 0 {
+		return exitParseError
+	}
+
+	if repaired != nil {
+		if err := os.WriteFile(rf.repairedMap, repaired.Bytes(), 0o644); err != nil {
+			fmt.Fprintf(stderr, "failed to write repaired map: %v\n", err)
+			return exitError
+		}
+	}
+
+	return exitOK
+}
+
+// plural returns the count followed by the noun, pluralized if needed
+func plural(count int, noun string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, noun)
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
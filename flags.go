@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/slog"
+
+	"codingtask/simulation"
+)
+
+// newFlagSet creates a flag set for a subcommand, usage is printed to stderr
+// in case of an error and to stdout when --help is requested
+func newFlagSet(name, synopsis string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s %s %s\n\nflags:\n", os.Args[0], name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the flags of a subcommand. The returned exit code is only
+// meaningful if ok is false, in which case the command should return it
+func parseFlags(fs *flag.FlagSet, args []string, stdout io.Writer) (code int, ok bool) {
+	// the flag set prints the usage itself on errors and on --help, always
+	// to the same output. Print it here instead so --help goes to stdout
+	usage := fs.Usage
+	fs.Usage = func() {}
+	err := fs.Parse(args)
+	fs.Usage = usage
+
+	if errors.Is(err, flag.ErrHelp) {
+		fs.SetOutput(stdout)
+		fs.Usage()
+		return exitOK, false
+	}
+	if err != nil {
+		fs.Usage()
+		return exitUsage, false
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %v\n", fs.Args())
+		fs.Usage()
+		return exitUsage, false
+	}
+
+	return exitOK, true
+}
+
+// usageError prints the error and the usage of the subcommand and returns
+// the exit code for usage errors
+func usageError(fs *flag.FlagSet, format string, args ...any) int {
+	fmt.Fprintf(fs.Output(), format+"\n", args...)
+	fs.Usage()
+	return exitUsage
+}
+
+// isFlagSet returns true if the flag with the given name was set on the
+// command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// logFlags are the logging flags shared by all subcommands
+type logFlags struct {
+	verbose bool
+	quiet   bool
+	format  string
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	f := &logFlags{}
+	fs.BoolVar(&f.verbose, "v", false, "verbose, log per-iteration details")
+	fs.BoolVar(&f.quiet, "q", false, "quiet, only log warnings and errors")
+	fs.StringVar(&f.format, "log-format", "text", "log format, one of: text, json")
+	return f
+}
+
+// newLogger creates the logger for diagnostics. The level is debug when
+// verbose, warn when quiet and info otherwise
+func (f *logFlags) newLogger(w io.Writer) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	switch {
+	case f.verbose && f.quiet:
+		return nil, fmt.Errorf("-v and -q cannot be used together")
+	case f.verbose:
+		level = slog.LevelDebug
+	case f.quiet:
+		level = slog.LevelWarn
+	}
+
+	opts := slog.HandlerOptions{Level: level}
+	switch f.format {
+	case "text":
+		return slog.New(opts.NewTextHandler(w)), nil
+	case "json":
+		return slog.New(opts.NewJSONHandler(w)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format: %s", f.format)
+}
+
+// mapFlags are the flags to read a map, shared by all subcommands reading a
+// map
+type mapFlags struct {
+	path          string
+	vocabulary    string
+	placementFile string
+}
+
+func addMapFlags(fs *flag.FlagSet) *mapFlags {
+	f := &mapFlags{}
+	fs.StringVar(&f.path, "map", "", "file to read the map from, the map is read from stdin when not set or set to -")
+	fs.StringVar(
+		&f.vocabulary,
+		"directions",
+		"",
+		fmt.Sprintf(
+			"vocabulary of directions the roads can have, one of: %s. Defaults to the @directions of the map or %s",
+			strings.Join(simulation.Vocabularies(), ", "),
+			simulation.DefaultVocabulary,
+		),
+	)
+	fs.StringVar(&f.placementFile, "placement-file", "", "file placing the aliens with lines like 'alien 3 Foo', replacing the placements of the map")
+	return f
+}
+
+func (f *mapFlags) validate() error {
+	if _, ok := simulation.VocabularyDirections(f.vocabulary); !ok {
+		return fmt.Errorf("unknown directions: %s", f.vocabulary)
+	}
+	return nil
+}
+
+// options returns the simulation options affecting how the map is parsed,
+// mapName is the name returned by openMap
+func (f *mapFlags) options(mapName string) []simulation.Option {
+	opts := []simulation.Option{simulation.WithVocabulary(f.vocabulary), simulation.WithSource(mapName)}
+	if f.placementFile != "" {
+		opts = append(opts, simulation.WithPlacementFile(f.placementFile))
+	}
+	return opts
+}
+
+// openMap opens the map file, or returns stdin if path is empty or "-". The
+// returned name is used in messages about the map
+func openMap(path string, stdin io.Reader) (r io.ReadCloser, name string, err error) {
+	if path == "" || path == "-" {
+		return io.NopCloser(stdin), "<stdin>", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to open map: %w", err)
+	}
+
+	return f, path, nil
+}
+
+// repairFlags are the flags to repair asymmetric roads in a map, shared by
+// the run and validate commands
+type repairFlags struct {
+	repair      bool
+	repairedMap string
+}
+
+func addRepairFlags(fs *flag.FlagSet) *repairFlags {
+	f := &repairFlags{}
+	fs.BoolVar(&f.repair, "repair", false, "add missing reverse roads and drop roads whose reverse direction leads to another city")
+	fs.StringVar(&f.repairedMap, "repaired-map", "", "file to write the repaired map to in canonical form, requires --repair")
+	return f
+}
+
+func (f *repairFlags) validate() error {
+	if f.repairedMap != "" && !f.repair {
+		return fmt.Errorf("--repaired-map requires --repair")
+	}
+	return nil
+}
+
+// mapErrorPosition returns the position of a map error compiler-style, e.g.
+// "map.txt:12:5", or only the map name if the error has no position. The
+// position is in the included map or the placement file if the error was
+// found in one
+func mapErrorPosition(mapName string, err error) string {
+	var parseErr *simulation.ParseError
+	if errors.As(err, &parseErr) {
+		if parseErr.File != "" {
+			mapName = parseErr.File
+		}
+		return fmt.Sprintf("%s:%d:%d", mapName, parseErr.Line, parseErr.Column)
+	}
+	return mapName
+}
@@ -2,38 +2,90 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"os"
-	"strconv"
+)
 
-	"codingtask/simulation"
+// exit codes
+const (
+	exitOK = 0
+	// unexpected error, e.g. failing to read or write a file
+	exitError = 1
+	// invalid command line usage
+	exitUsage = 2
+	// the map could not be parsed or is invalid
+	exitParseError = 3
+	// the run command exits with one code per end state of the simulation
+	exitAllAliensDeadOrTrapped  = 10
+	exitOnlyOneAlienLeft        = 11
+	exitAliveAliensDisconnected = 12
+	exitMaxIterationsReached    = 13
+	exitInterrupted             = 14
+	exitTooFewAliensLeft        = 15
 )
 
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"run", "run a simulation on a map", runCommand},
+	{"validate", "check a map for errors", validateCommand},
+	{"generate", "generate a map", generateCommand},
+	{"batch", "run many simulations on the same map with consecutive seeds", batchCommand},
+	{"analyze", "print statistics about a map", analyzeCommand},
+}
+
 func main() {
-	// First argument is the number of aliens to run
-	nrOfAliens, err := strconv.Atoi(os.Args[1])
-	if err != nil {
-		log.Fatalf("failed to parse number of aliens: %v", err)
-	}
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
 
-	if nrOfAliens < 2 {
-		log.Fatalf("number of aliens must be greater than 1")
+// run runs the subcommand given as the first argument and returns the exit
+// code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		usage(stderr)
+		return exitUsage
 	}
 
-	// Read and parse input from STDIN
-	sim, err := simulation.NewSimulation(os.Stdin, nrOfAliens)
-	if err != nil {
-		log.Fatalf("failed to create simulation: %v", err)
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
 	}
 
-	// Run simulation
-	state, err := sim.Run()
-	if err != nil {
-		log.Fatalf("failed to run simulation: %v", err)
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdin, stdout, stderr)
+		}
 	}
 
-	log.Printf("simulation ended with state: %s", state)
+	fmt.Fprintf(stderr, "unknown command: %s\n\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\ncommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, `
+run '%s <command> --help' for the flags of a command
 
-	// Print simulation result
-	fmt.Println(sim.CitiesToString(sim.SurvivedCities()))
+exit codes:
+  0   success
+  1   unexpected error, e.g. failing to read or write a file
+  2   invalid command line usage
+  3   the map could not be parsed or is invalid
+  10  run: all aliens are dead or trapped
+  11  run: only one alien is left
+  12  run: the alive aliens cannot reach each other
+  13  run: the maximum number of iterations or moves has been reached
+  14  run, batch: interrupted by SIGINT or SIGTERM
+  15  run: fewer aliens than needed for a battle are left
+`, os.Args[0])
 }
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name           string
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+	}{
+		{
+			name:         "no command",
+			args:         []string{},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "unknown command",
+			args:         []string{"invade"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "help",
+			args:         []string{"--help"},
+			expectedCode: exitOK,
+		},
+		{
+			name:         "command help",
+			args:         []string{"run", "--help"},
+			expectedCode: exitOK,
+		},
+		{
+			name:         "unknown flag",
+			args:         []string{"run", "--invade"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run without aliens",
+			args:         []string{"run", "--map", "testdata/input.txt"},
+			expectedCode: exitParseError,
+		},
+		{
+			name:         "run with too few aliens",
+			args:         []string{"run", "--aliens", "1", "--map", "testdata/input.txt"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with aliens from the map header",
+			args:         []string{"run", "-q", "--seed", "1", "--map", "testdata/include/world.map"},
+			expectedCode: exitAllAliensDeadOrTrapped,
+		},
+		{
+			name:         "run with invalid map",
+			args:         []string{"run", "--aliens", "2"},
+			stdin:        "A north=B",
+			expectedCode: exitParseError,
+		},
+		{
+			name:         "run until all aliens are dead or trapped",
+			args:         []string{"run", "-q", "--aliens", "4", "--seed", "3", "--map", "testdata/input.txt"},
+			expectedCode: exitAllAliensDeadOrTrapped,
+			expectedStdout: `Bar has been destroyed by alien 3 and alien 4!
+Qux has been destroyed by alien 1 and alien 2!
+Baz east=Zor south=Jaz
+Fex west=Jaz
+Jaz east=Fex north=Baz
+Vex south=Xaz
+Xaz north=Vex west=Zox
+Zor west=Baz
+Zox east=Xaz
+
+`,
+		},
+		{
+			name:         "run until max iterations",
+			args:         []string{"run", "-q", "--aliens", "2", "--seed", "1", "--max-iterations", "1"},
+			stdin:        "A north=B\nB south=A north=C\nC south=B north=D\nD south=C",
+			expectedCode: exitMaxIterationsReached,
+		},
+		{
+			name:           "validate valid map",
+			args:           []string{"validate", "--map", "testdata/input.txt"},
+			expectedCode:   exitOK,
+			expectedStdout: "testdata/input.txt: ok\n",
+		},
+		{
+			name:         "validate invalid map",
+			args:         []string{"validate"},
+			stdin:        "A north=B west=C\nB south=A south=A\nC",
+			expectedCode: exitParseError,
+			expectedStdout: `<stdin>:1:11: error: neighbor city 'C' has no road in direction 'east' to city 'A'
+<stdin>:2:11: warning: duplicate road in direction 'south' for city 'B'
+<stdin>: 1 error, 1 warning
+`,
+		},
+		{
+			name:         "validate with repair",
+			args:         []string{"validate", "--repair"},
+			stdin:        "A north=B west=C\nB south=A\nC east=B",
+			expectedCode: exitOK,
+			expectedStdout: `<stdin>:1:11: repair: dropped road 'west=C' from city 'A', road 'east' of city 'C' leads to 'B'
+<stdin>:3:3: repair: added road 'west=C' to city 'B'
+<stdin>: ok, 2 repairs
+`,
+		},
+		{
+			name:           "validate with directions",
+			args:           []string{"validate", "--directions", "compass3d"},
+			stdin:          "A up=B\nB down=A",
+			expectedCode:   exitOK,
+			expectedStdout: "<stdin>: ok\n",
+		},
+		{
+			name:         "validate with unknown directions",
+			args:         []string{"validate", "--directions", "polar"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:           "validate map with includes",
+			args:           []string{"validate", "--map", "testdata/include/world.map"},
+			expectedCode:   exitOK,
+			expectedStdout: "testdata/include/world.map: ok\n",
+		},
+		{
+			name:         "validate map with error in include",
+			args:         []string{"validate", "--map", "testdata/include/broken.map"},
+			expectedCode: exitParseError,
+			expectedStdout: `testdata/include/broken.map:2:10: error: failed to include 'missing.map': open testdata/include/missing.map: no such file or directory
+testdata/include/west.map:3:19: error: unknown neighbor city 'Baz' in direction 'east' for city 'Bar'
+testdata/include/broken.map: 2 errors, 0 warnings
+`,
+		},
+		{
+			name:           "validate JSON map",
+			args:           []string{"validate", "--map", "testdata/input.json"},
+			expectedCode:   exitOK,
+			expectedStdout: "testdata/input.json: ok\n",
+		},
+		{
+			name:         "validate invalid JSON map",
+			args:         []string{"validate"},
+			stdin:        `{"cities": [{"name": "A", "roads": [{"direction": "north", "city": "B"}]}]}`,
+			expectedCode: exitParseError,
+			expectedStdout: `<stdin>:1:37: error: unknown neighbor city 'B' in direction 'north' for city 'A'
+<stdin>: 1 error, 0 warnings
+`,
+		},
+		{
+			name:         "run with JSON map output",
+			args:         []string{"run", "-q", "--seed", "1", "--output-format", "json-map", "--map", "testdata/input.json"},
+			expectedCode: exitAllAliensDeadOrTrapped,
+			expectedStdout: `{
+  "schema_version": 1,
+  "name": "Small world",
+  "cities": [
+    {
+      "name": "Foo",
+      "attributes": {
+        "population": 1200
+      }
+    },
+    {
+      "name": "New York"
+    }
+  ]
+}
+`,
+		},
+		{
+			name:         "validate repaired map without repair",
+			args:         []string{"validate", "--repaired-map", "map.txt"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with unknown DOT view",
+			args:         []string{"run", "--aliens", "2", "--dot", "map.dot", "--dot-view", "sideways"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with repair",
+			args:         []string{"run", "-q", "--aliens", "2", "--seed", "1", "--repair"},
+			stdin:        "A north=B\nB",
+			expectedCode: exitAllAliensDeadOrTrapped,
+		},
+		{
+			name:         "run with movement strategy",
+			args:         []string{"run", "-q", "--aliens", "2", "--seed", "1", "--strategy", "seek-nearest-alien", "--alien-strategy", "2=never-stay"},
+			stdin:        "A north=B\nB south=A north=C\nC south=B north=D\nD south=C",
+			expectedCode: exitAllAliensDeadOrTrapped,
+		},
+		{
+			name:         "run with unknown movement strategy",
+			args:         []string{"run", "--aliens", "2", "--strategy", "teleport"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with invalid alien strategy",
+			args:         []string{"run", "--aliens", "2", "--alien-strategy", "0=uniform"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with strategy of missing alien",
+			args:         []string{"run", "-q", "--aliens", "2", "--alien-strategy", "3=uniform"},
+			stdin:        "A north=B\nB south=A",
+			expectedCode: exitParseError,
+		},
+		{
+			name:         "run with battle rule",
+			args:         []string{"run", "-q", "--aliens", "4", "--seed", "3", "--battle-rule", "last-alien-standing", "--min-combatants", "3", "--alien-strength", "3=2", "--map", "testdata/input.txt"},
+			expectedCode: exitTooFewAliensLeft,
+		},
+		{
+			name:         "run with several battle rules",
+			args:         []string{"run", "--aliens", "2", "--battle-rule", "destroy-all,city-survives"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with too few combatants",
+			args:         []string{"run", "--aliens", "2", "--min-combatants", "1"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with invalid alien strength",
+			args:         []string{"run", "--aliens", "2", "--alien-strength", "1=-2"},
+			expectedCode: exitUsage,
+		},
+		{
+			name: "run with placement file",
+			args: []string{"run", "-q", "--placement", "explicit", "--placement-file", "testdata/placements.txt", "--max-iterations", "1", "--seed", "1", "--map", "testdata/input.txt"},
+			expectedStdout: `Bar has been destroyed by alien 1 and alien 2!
+Baz east=Zor north=Qux south=Jaz
+Fex west=Jaz
+Jaz east=Fex north=Baz
+Qux north=Zox south=Baz
+Vex south=Xaz
+Xaz north=Vex west=Zox
+Zor west=Baz
+Zox east=Xaz south=Qux
+
+`,
+			expectedCode: exitMaxIterationsReached,
+		},
+		{
+			name:         "run with unknown placement",
+			args:         []string{"run", "--aliens", "2", "--placement", "everywhere"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with clustered placement without center",
+			args:         []string{"run", "--aliens", "2", "--placement", "clustered"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with unplaced aliens",
+			args:         []string{"run", "-q", "--aliens", "5", "--placement", "explicit", "--placement-file", "testdata/placements.txt", "--map", "testdata/input.txt"},
+			expectedCode: exitParseError,
+		},
+		{
+			name:         "validate with invalid placement file",
+			args:         []string{"validate", "--placement-file", "testdata/placements.txt"},
+			stdin:        "A north=B\nB south=A",
+			expectedCode: exitParseError,
+		},
+		{
+			name: "run with simultaneous moves",
+			args: []string{"run", "-q", "--aliens", "6", "--seed", "2", "--simultaneous", "--map", "testdata/input.txt"},
+			expectedStdout: `alien 3 and alien 5 met on the road between Fex and Jaz, no alien survived!
+Qux has been destroyed by alien 1 and alien 6!
+Vex has been destroyed by alien 4 and alien 2!
+Bar south=Zor west=Fex
+Baz east=Zor south=Jaz
+Fex east=Bar west=Jaz
+Jaz east=Fex north=Baz
+Xaz west=Zox
+Zor north=Bar west=Baz
+Zox east=Xaz
+
+`,
+			expectedCode: exitAllAliensDeadOrTrapped,
+		},
+		{
+			name:         "run with unknown crossing outcome",
+			args:         []string{"run", "--aliens", "2", "--simultaneous", "--crossing", "swap"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "run with crossing without simultaneous moves",
+			args:         []string{"run", "--aliens", "2", "--crossing", "bounce"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "analyze",
+			args:         []string{"analyze"},
+			stdin:        "A north=B\nB south=A\nC",
+			expectedCode: exitOK,
+			expectedStdout: `cities:             3
+roads:              1
+one-way roads:      0
+isolated cities:    1
+components:         2
+largest component:  2
+max roads per city: 1
+`,
+		},
+		{
+			name:         "generate",
+			args:         []string{"generate", "--width", "2", "--height", "1"},
+			expectedCode: exitOK,
+			expectedStdout: `C0_0 east=C0_1
+C0_1 west=C0_0
+`,
+		},
+		{
+			name:         "generate torus with isolated cities",
+			args:         []string{"generate", "--topology", "torus", "--width", "3", "--height", "3", "--isolated", "1"},
+			expectedCode: exitOK,
+			expectedStdout: `C0_0 east=C0_1 north=C2_0 south=C1_0 west=C0_2
+C0_1 east=C0_2 north=C2_1 south=C1_1 west=C0_0
+C0_2 east=C0_0 north=C2_2 south=C1_2 west=C0_1
+C1_0 east=C1_1 north=C0_0 south=C2_0 west=C1_2
+C1_1 east=C1_2 north=C0_1 south=C2_1 west=C1_0
+C1_2 east=C1_0 north=C0_2 south=C2_2 west=C1_1
+C2_0 east=C2_1 north=C1_0 south=C0_0 west=C2_2
+C2_1 east=C2_2 north=C1_1 south=C0_1 west=C2_0
+C2_2 east=C2_0 north=C1_2 south=C0_2 west=C2_1
+I0
+`,
+		},
+		{
+			name:         "generate with invalid removal",
+			args:         []string{"generate", "--removal", "1.5"},
+			expectedCode: exitUsage,
+		},
+		{
+			name:         "batch",
+			args:         []string{"batch", "-q", "--aliens", "4", "--seed", "3", "--runs", "2", "--output-format", "csv", "--map", "testdata/input.txt"},
+			expectedCode: exitOK,
+			expectedStdout: `seed,state,iterations,destroyed_cities,alive_aliens,battle_rule
+3,STATE_ALL_ALIENS_DEAD_OR_TRAPPED,22,2,0,destroy-all
+4,STATE_ALL_ALIENS_DEAD_OR_TRAPPED,13,2,0,destroy-all
+`,
+		},
+		{
+			name:         "batch comparing battle rules",
+			args:         []string{"batch", "-q", "--aliens", "4", "--seed", "3", "--runs", "2", "--battle-rule", "destroy-all,last-alien-standing", "--map", "testdata/input.txt"},
+			expectedCode: exitOK,
+			expectedStdout: `seed 3, destroy-all: STATE_ALL_ALIENS_DEAD_OR_TRAPPED after 22 iterations, 2 cities destroyed, 0 aliens alive
+seed 3, last-alien-standing: STATE_ONLY_ONE_ALIEN_LEFT after 11 iterations, 0 cities destroyed, 1 aliens alive
+seed 4, destroy-all: STATE_ALL_ALIENS_DEAD_OR_TRAPPED after 13 iterations, 2 cities destroyed, 0 aliens alive
+seed 4, last-alien-standing: STATE_ONLY_ONE_ALIEN_LEFT after 22 iterations, 0 cities destroyed, 1 aliens alive
+
+4 runs
+destroy-all STATE_ALL_ALIENS_DEAD_OR_TRAPPED: 2
+last-alien-standing STATE_ONLY_ONE_ALIEN_LEFT: 2
+`,
+		},
+		{
+			name:         "batch with unknown battle rule",
+			args:         []string{"batch", "--aliens", "4", "--battle-rule", "destroy-all,duel"},
+			expectedCode: exitUsage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stdout := &strings.Builder{}
+			stderr := &strings.Builder{}
+
+			code := run(tc.args, strings.NewReader(tc.stdin), stdout, stderr)
+			assert.Equal(t, tc.expectedCode, code, "unexpected exit code, stderr: %s", stderr.String())
+			if tc.expectedStdout != "" {
+				assert.Equal(t, tc.expectedStdout, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_generatedMapIsValid(t *testing.T) {
+	path := t.TempDir() + "/map.txt"
+
+	stdout, stderr := &strings.Builder{}, &strings.Builder{}
+	code := run([]string{"generate", "--topology", "tree", "--width", "5", "--height", "4", "--extra-roads", "3", "--seed", "1", "--out", path}, strings.NewReader(""), stdout, stderr)
+	assert.Equal(t, exitOK, code, "expected the map to be generated, stderr: %s", stderr.String())
+
+	stdout, stderr = &strings.Builder{}, &strings.Builder{}
+	code = run([]string{"validate", "--map", path}, strings.NewReader(""), stdout, stderr)
+	assert.Equal(t, exitOK, code, "expected the generated map to be valid, stdout: %s, stderr: %s", stdout.String(), stderr.String())
+}
+
+func TestRun_dot(t *testing.T) {
+	path := t.TempDir() + "/map.dot"
+
+	args := []string{"run", "-q", "--aliens", "2", "--seed", "1", "--dot", path, "--dot-view", "overlay"}
+	stderr := &strings.Builder{}
+	code := run(args, strings.NewReader("A north=B\nB south=A"), &strings.Builder{}, stderr)
+	assert.Equal(t, exitAllAliensDeadOrTrapped, code, "unexpected exit code, stderr: %s", stderr.String())
+
+	dot, err := os.ReadFile(path)
+	assert.NoError(t, err, "expected the DOT graph to be written")
+	assert.Contains(t, string(dot), `"A" -> "B" [taillabel="north", headlabel="south", dir=both, style=dashed, color=gray];`)
+}
+
+func TestRun_seed(t *testing.T) {
+	// the seed is reported even when quiet, unless it was set with --seed
+	for _, command := range []string{"run", "batch"} {
+		t.Run(command, func(t *testing.T) {
+			stderr := &strings.Builder{}
+			run([]string{command, "-q", "--aliens", "2"}, strings.NewReader("A north=B\nB south=A"), &strings.Builder{}, stderr)
+			assert.Regexp(t, `^using seed -?\d+\n`, stderr.String())
+
+			stderr = &strings.Builder{}
+			run([]string{command, "-q", "--aliens", "2", "--seed", "1"}, strings.NewReader("A north=B\nB south=A"), &strings.Builder{}, stderr)
+			assert.NotContains(t, stderr.String(), "using seed")
+		})
+	}
+}
+
+func TestRun_placementFileError(t *testing.T) {
+	// the position of a placement file error is not logged as the map
+	stderr := &strings.Builder{}
+	code := run([]string{"run", "-q", "--seed", "1", "--placement-file", "testdata/placements.txt"}, strings.NewReader("A north=B\nB south=A"), &strings.Builder{}, stderr)
+	assert.Equal(t, exitParseError, code)
+	assert.Contains(t, stderr.String(), "pos=testdata/placements.txt:2:1")
+	assert.NotContains(t, stderr.String(), "map=")
+}
@@ -0,0 +1,248 @@
+// Package mapgen generates maps in the line format read by the simulation
+// package. Generated maps are always valid: every road has a road back in the
+// opposite direction
+package mapgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Topology is the shape of a generated map
+type Topology string
+
+// topologies
+const (
+	// width×height grid, every city is connected to its horizontal and
+	// vertical neighbors
+	TopologyGrid Topology = "grid"
+	// grid whose edges wrap around, the cities in the last column are
+	// connected to the first column and the last row to the first row
+	TopologyTorus Topology = "torus"
+	// random spanning tree of the grid, every city can reach every other city
+	// on exactly one path. ExtraRoads adds roads of the grid to create cycles
+	TopologyTree Topology = "tree"
+)
+
+// Topologies returns the names of all topologies
+func Topologies() []string {
+	return []string{string(TopologyGrid), string(TopologyTorus), string(TopologyTree)}
+}
+
+// Config configures the generated map
+type Config struct {
+	Topology Topology
+	// Width and Height are the number of columns and rows of the grid
+	Width  int
+	Height int
+	// Removal is the fraction of roads randomly removed from the map, from 0
+	// to less than 1
+	Removal float64
+	// ExtraRoads is the number of roads of the grid added to the spanning tree
+	// of TopologyTree
+	ExtraRoads int
+	// Isolated is the number of cities without roads added to the map, they
+	// are named I0, I1, ...
+	Isolated int
+	// Seed seeds the random source, the same config always generates the same
+	// map
+	Seed int64
+}
+
+// Validate returns an error if the config cannot be used to generate a map
+func (cfg Config) Validate() error {
+	switch cfg.Topology {
+	case TopologyGrid, TopologyTree:
+		if cfg.Width < 1 || cfg.Height < 1 {
+			return fmt.Errorf("width and height must be greater than 0, got %dx%d", cfg.Width, cfg.Height)
+		}
+	case TopologyTorus:
+		// smaller tori would have roads leading back to the city itself
+		if cfg.Width < 3 || cfg.Height < 3 {
+			return fmt.Errorf("width and height of a torus must be at least 3, got %dx%d", cfg.Width, cfg.Height)
+		}
+	default:
+		return fmt.Errorf("unknown topology: %s", cfg.Topology)
+	}
+
+	if cfg.Removal < 0 || cfg.Removal >= 1 {
+		return fmt.Errorf("removal must be at least 0 and less than 1, got %g", cfg.Removal)
+	}
+
+	if cfg.ExtraRoads < 0 {
+		return fmt.Errorf("extra roads must not be negative, got %d", cfg.ExtraRoads)
+	}
+	if cfg.ExtraRoads > 0 {
+		if cfg.Topology != TopologyTree {
+			return fmt.Errorf("extra roads can only be added to a %s", TopologyTree)
+		}
+		// a spanning tree of the grid uses all but one road per city
+		available := cfg.Width*(cfg.Height-1) + cfg.Height*(cfg.Width-1) - (cfg.Width*cfg.Height - 1)
+		if cfg.ExtraRoads > available {
+			return fmt.Errorf("a %dx%d grid has only %d roads which are not part of the tree, got %d extra roads", cfg.Width, cfg.Height, available, cfg.ExtraRoads)
+		}
+	}
+
+	if cfg.Isolated < 0 {
+		return fmt.Errorf("number of isolated cities must not be negative, got %d", cfg.Isolated)
+	}
+
+	return nil
+}
+
+// IsRandom returns true if the map generated for cfg depends on the seed
+func (cfg Config) IsRandom() bool {
+	return cfg.Topology == TopologyTree || cfg.Removal > 0
+}
+
+// grid contains the roads of a generated map. The road east of the city in
+// row r and column c has the index r*width+c in east, the road south of it
+// the same index in south
+type grid struct {
+	width, height int
+	east, south   []bool
+}
+
+func newGrid(width, height int) *grid {
+	return &grid{width: width, height: height, east: make([]bool, width*height), south: make([]bool, width*height)}
+}
+
+// neighbor returns the index of the city in the given row and column, which
+// wrap around
+func (g *grid) neighbor(row, col int) int {
+	return (row+g.height)%g.height*g.width + (col+g.width)%g.width
+}
+
+// Generate writes a map generated according to cfg to w. Cities are named
+// after their position in the grid, e.g. C0_0 for the city in the top left
+// corner and C0_1 for the city east of it
+func Generate(w io.Writer, cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	g := newGrid(cfg.Width, cfg.Height)
+	switch cfg.Topology {
+	case TopologyGrid:
+		for i := range g.east {
+			g.east[i] = i%g.width < g.width-1
+			g.south[i] = i/g.width < g.height-1
+		}
+	case TopologyTorus:
+		for i := range g.east {
+			g.east[i] = true
+			g.south[i] = true
+		}
+	case TopologyTree:
+		spanningTree(g, cfg.ExtraRoads, rnd)
+	}
+
+	if cfg.Removal > 0 {
+		for i := range g.east {
+			g.east[i] = g.east[i] && rnd.Float64() >= cfg.Removal
+			g.south[i] = g.south[i] && rnd.Float64() >= cfg.Removal
+		}
+	}
+
+	writer := bufio.NewWriter(w)
+	builder := strings.Builder{}
+	for row := 0; row < cfg.Height; row++ {
+		for col := 0; col < cfg.Width; col++ {
+			builder.Reset()
+			builder.WriteString(cityName(row, col))
+
+			// roads are written in the same order as the simulation writes
+			// them: east, north, south, west
+			if g.east[g.neighbor(row, col)] {
+				builder.WriteString(" east=" + cityName(row, (col+1)%g.width))
+			}
+			if g.south[g.neighbor(row-1, col)] {
+				builder.WriteString(" north=" + cityName((row-1+g.height)%g.height, col))
+			}
+			if g.south[g.neighbor(row, col)] {
+				builder.WriteString(" south=" + cityName((row+1)%g.height, col))
+			}
+			if g.east[g.neighbor(row, col-1)] {
+				builder.WriteString(" west=" + cityName(row, (col-1+g.width)%g.width))
+			}
+			builder.WriteString("\n")
+
+			if _, err := writer.WriteString(builder.String()); err != nil {
+				return fmt.Errorf("failed to write map: %w", err)
+			}
+		}
+	}
+
+	for i := 0; i < cfg.Isolated; i++ {
+		if _, err := writer.WriteString("I" + strconv.Itoa(i) + "\n"); err != nil {
+			return fmt.Errorf("failed to write map: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write map: %w", err)
+	}
+
+	return nil
+}
+
+// spanningTree adds the roads of a random spanning tree of the grid to g,
+// using Kruskal's algorithm on the roads in random order. The first extra
+// roads which would close a cycle are added as well
+func spanningTree(g *grid, extra int, rnd *rand.Rand) {
+	// roads are numbered 2*i for the road east of city i and 2*i+1 for the
+	// road south of it
+	roads := make([]int, 0, 2*g.width*g.height)
+	for i := 0; i < g.width*g.height; i++ {
+		if i%g.width < g.width-1 {
+			roads = append(roads, 2*i)
+		}
+		if i/g.width < g.height-1 {
+			roads = append(roads, 2*i+1)
+		}
+	}
+	rnd.Shuffle(len(roads), func(i, j int) { roads[i], roads[j] = roads[j], roads[i] })
+
+	parent := make([]int, g.width*g.height)
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	for _, road := range roads {
+		from := road / 2
+		to := from + 1
+		if road%2 == 1 {
+			to = from + g.width
+		}
+
+		if a, b := find(from), find(to); a != b {
+			parent[a] = b
+		} else if extra > 0 {
+			extra--
+		} else {
+			continue
+		}
+
+		if road%2 == 0 {
+			g.east[from] = true
+		} else {
+			g.south[from] = true
+		}
+	}
+}
+
+func cityName(row, col int) string {
+	return "C" + strconv.Itoa(row) + "_" + strconv.Itoa(col)
+}
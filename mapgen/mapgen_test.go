@@ -0,0 +1,139 @@
+package mapgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"codingtask/simulation"
+)
+
+func TestGenerate_grid(t *testing.T) {
+	builder := &strings.Builder{}
+	err := Generate(builder, Config{Topology: TopologyGrid, Width: 3, Height: 2})
+	assert.NoError(t, err, "expected no error when generating grid")
+	assert.Equal(t, `C0_0 east=C0_1 south=C1_0
+C0_1 east=C0_2 south=C1_1 west=C0_0
+C0_2 south=C1_2 west=C0_1
+C1_0 east=C1_1 north=C0_0
+C1_1 east=C1_2 north=C0_1 west=C1_0
+C1_2 north=C0_2 west=C1_1
+`, builder.String())
+
+	stats, err := simulation.AnalyzeMap(strings.NewReader(builder.String()))
+	assert.NoError(t, err, "expected the generated map to be valid")
+	assert.Equal(t, 6, stats.Cities)
+	assert.Equal(t, 7, stats.Roads)
+	assert.Equal(t, 1, stats.Components)
+}
+
+func TestGenerate_invalidConfig(t *testing.T) {
+	err := Generate(&strings.Builder{}, Config{Topology: TopologyGrid, Width: 0, Height: 2})
+	assert.EqualError(t, err, "width and height must be greater than 0, got 0x2")
+
+	err = Generate(&strings.Builder{}, Config{Topology: "hexagon", Width: 2, Height: 2})
+	assert.EqualError(t, err, "unknown topology: hexagon")
+
+	err = Generate(&strings.Builder{}, Config{Topology: TopologyTorus, Width: 2, Height: 3})
+	assert.EqualError(t, err, "width and height of a torus must be at least 3, got 2x3")
+
+	err = Generate(&strings.Builder{}, Config{Topology: TopologyGrid, Width: 2, Height: 2, Removal: 1})
+	assert.EqualError(t, err, "removal must be at least 0 and less than 1, got 1")
+
+	err = Generate(&strings.Builder{}, Config{Topology: TopologyGrid, Width: 2, Height: 2, ExtraRoads: 1})
+	assert.EqualError(t, err, "extra roads can only be added to a tree")
+
+	err = Generate(&strings.Builder{}, Config{Topology: TopologyTree, Width: 2, Height: 2, ExtraRoads: 2})
+	assert.EqualError(t, err, "a 2x2 grid has only 1 roads which are not part of the tree, got 2 extra roads")
+
+	err = Generate(&strings.Builder{}, Config{Topology: TopologyGrid, Width: 2, Height: 2, Isolated: -1})
+	assert.EqualError(t, err, "number of isolated cities must not be negative, got -1")
+}
+
+func TestGenerate_torus(t *testing.T) {
+	builder := &strings.Builder{}
+	err := Generate(builder, Config{Topology: TopologyTorus, Width: 3, Height: 3})
+	assert.NoError(t, err, "expected no error when generating torus")
+	assert.Equal(t, `C0_0 east=C0_1 north=C2_0 south=C1_0 west=C0_2
+C0_1 east=C0_2 north=C2_1 south=C1_1 west=C0_0
+C0_2 east=C0_0 north=C2_2 south=C1_2 west=C0_1
+C1_0 east=C1_1 north=C0_0 south=C2_0 west=C1_2
+C1_1 east=C1_2 north=C0_1 south=C2_1 west=C1_0
+C1_2 east=C1_0 north=C0_2 south=C2_2 west=C1_1
+C2_0 east=C2_1 north=C1_0 south=C0_0 west=C2_2
+C2_1 east=C2_2 north=C1_1 south=C0_1 west=C2_0
+C2_2 east=C2_0 north=C1_2 south=C0_2 west=C2_1
+`, builder.String())
+
+	stats, err := simulation.AnalyzeMap(strings.NewReader(builder.String()))
+	assert.NoError(t, err, "expected the generated map to be valid")
+	assert.Equal(t, 18, stats.Roads)
+	assert.Equal(t, 4, stats.MaxRoads)
+}
+
+func TestGenerate_tree(t *testing.T) {
+	testCases := []struct {
+		name       string
+		extraRoads int
+	}{
+		{"spanning tree", 0},
+		{"with extra roads", 5},
+		{"with all roads", 20},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := &strings.Builder{}
+			err := Generate(builder, Config{Topology: TopologyTree, Width: 5, Height: 6, ExtraRoads: tc.extraRoads, Seed: 7})
+			assert.NoError(t, err, "expected no error when generating tree")
+
+			stats, err := simulation.AnalyzeMap(strings.NewReader(builder.String()))
+			assert.NoError(t, err, "expected the generated map to be valid")
+			assert.Equal(t, 30, stats.Cities)
+			assert.Equal(t, 29+tc.extraRoads, stats.Roads, "expected a tree and the extra roads")
+			assert.Equal(t, 1, stats.Components, "expected every city to be reachable")
+		})
+	}
+}
+
+func TestGenerate_removal(t *testing.T) {
+	cfg := Config{Topology: TopologyGrid, Width: 20, Height: 20, Removal: 0.5, Seed: 3}
+
+	first := &strings.Builder{}
+	assert.NoError(t, Generate(first, cfg))
+	second := &strings.Builder{}
+	assert.NoError(t, Generate(second, cfg))
+	assert.Equal(t, first.String(), second.String(), "expected the same seed to generate the same map")
+
+	cfg.Seed = 4
+	other := &strings.Builder{}
+	assert.NoError(t, Generate(other, cfg))
+	assert.NotEqual(t, first.String(), other.String(), "expected another seed to generate another map")
+
+	stats, err := simulation.AnalyzeMap(strings.NewReader(first.String()))
+	assert.NoError(t, err, "expected the generated map to be valid")
+	// a full 20x20 grid has 760 roads, about half of them are removed
+	assert.InDelta(t, 380, stats.Roads, 60)
+}
+
+func TestGenerate_isolated(t *testing.T) {
+	builder := &strings.Builder{}
+	err := Generate(builder, Config{Topology: TopologyGrid, Width: 2, Height: 1, Isolated: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "C0_0 east=C0_1\nC0_1 west=C0_0\nI0\nI1\n", builder.String())
+}
+
+func TestGenerate_large(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large map in short mode")
+	}
+
+	builder := &strings.Builder{}
+	err := Generate(builder, Config{Topology: TopologyTree, Width: 300, Height: 300, ExtraRoads: 1000, Removal: 0.1, Seed: 1})
+	assert.NoError(t, err)
+
+	stats, err := simulation.AnalyzeMap(strings.NewReader(builder.String()))
+	assert.NoError(t, err, "expected the generated map to be valid")
+	assert.Equal(t, 90000, stats.Cities)
+}
@@ -2,13 +2,30 @@ package simulation
 
 import (
 	"fmt"
-	"log"
 	"math/rand"
 )
 
 type alien struct {
 	name        int
 	currentCity *city
+	// moves is the number of times the alien has moved to another city,
+	// stays the number of iterations it stayed in its city while able to move
+	moves int
+	stays int
+	// visited contains the names of the cities the alien has been in, in
+	// order, starting with the city it was placed in
+	visited []string
+	// trapped is set once the alien has been reported as trapped
+	trapped bool
+	// fateIteration and fateCity record the iteration and the city in which
+	// the alien died or became trapped
+	fateIteration int
+	fateCity      string
+	// strategy decides where the alien moves, uniformMovement is used when
+	// nil
+	strategy MovementStrategy
+	// strength is the strength of the alien in battles, see Combatant
+	strength float64
 }
 
 func (a *alien) string() string {
@@ -29,37 +46,79 @@ func (a *alien) isTrapped() bool {
 	return !a.isDead() && !a.currentCity.isDestroyed() && len(a.currentCity.neighbors) == 0
 }
 
-// move moves the alien randomly from a city to one of its neighbors if
-// possible. The alien may also stay at the same city, the return boolean value
-// indicates if the alien moved or not
-func (a *alien) move() bool {
+// move moves the alien from a city to one of its neighbors if possible, the
+// road is picked by the movement strategy of the alien using rnd as the source
+// of randomness. The alien may also stay at the same city, the return boolean
+// value indicates if the alien moved or not. An error is returned if the
+// strategy picks a direction the city has no road in
+func (a *alien) move(cities map[string]*city, rnd *rand.Rand) (bool, error) {
+	next, err := a.plan(cities, rnd)
+	if err != nil {
+		return false, err
+	}
+	if next == nil {
+		a.stay()
+		return false, nil
+	}
+
+	a.goToCity(next)
+	a.moves++
+
+	return true, nil
+}
+
+// stay records that the alien stayed in its city for an iteration, dead and
+// trapped aliens are not counted as staying
+func (a *alien) stay() {
+	if !a.isDead() && !a.isTrapped() {
+		a.stays++
+	}
+}
+
+// hasMovesLeft returns true if the alien has made fewer than max moves,
+// staying counts as a move so that aliens that keep staying use up their
+// moves as well. A max of 0 or less means no limit
+func (a *alien) hasMovesLeft(max int) bool {
+	return max <= 0 || a.moves+a.stays < max
+}
+
+// plan returns the city the movement strategy of the alien picks to move to
+// next without moving the alien, or nil if the alien stays. Dead and trapped
+// aliens always stay
+func (a *alien) plan(cities map[string]*city, rnd *rand.Rand) (*city, error) {
 	if a.isDead() || a.isTrapped() {
-		return false
+		return nil, nil
 	}
 
-	// randomly pick a neighbor
-	directions := make([]direction, len(a.currentCity.neighbors))
-	i := 0
-	for d := range a.currentCity.neighbors {
-		directions[i] = d
-		i++
+	strategy := a.strategy
+	if strategy == nil {
+		strategy = MovementFunc(uniformMovement)
 	}
 
-	moveDirectionIndex := rand.Intn(len(directions)+1) - 1
+	d := strategy.Move(Surroundings{alien: a, cities: cities}, rnd)
 	// alien decided to stay
-	if moveDirectionIndex < 0 {
-		return false
+	if d == Stay {
+		return nil, nil
+	}
+
+	neighbor, ok := a.currentCity.neighbors[direction(d)]
+	if !ok {
+		return nil, fmt.Errorf("movement strategy of alien %d picked direction '%s', but city '%s' has no road in that direction", a.name, d, a.currentCity.name)
 	}
 
-	a.goToCity(a.currentCity.neighbors[directions[moveDirectionIndex]])
+	return neighbor, nil
+}
 
-	return true
+// land places the alien in city c before the first iteration
+func (a *alien) land(c *city) {
+	c.visitingAliens = append(c.visitingAliens, a)
+	a.currentCity = c
+	a.visited = []string{c.name}
 }
 
 func (a *alien) goToCity(c *city) {
 	a.currentCity.removeAlien(a)
 	a.currentCity = c
 	a.currentCity.visitingAliens = append(a.currentCity.visitingAliens, a)
-
-	log.Printf("%s moved to %s", a.string(), c.name)
+	a.visited = append(a.visited, c.name)
 }
@@ -1,6 +1,7 @@
 package simulation
 
 import (
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,7 +41,8 @@ func TestAlien_move(t *testing.T) {
 	}
 	alien := &alien{name: 1, currentCity: city1}
 
-	moved := alien.move()
+	moved, err := alien.move(nil, rand.New(rand.NewSource(1)))
+	assert.NoError(t, err)
 
 	if moved {
 		assert.Equal(t, city2, alien.currentCity, "expected alien to move from City1 to City2")
@@ -49,6 +51,21 @@ func TestAlien_move(t *testing.T) {
 	}
 }
 
+func TestAlien_move_invalidDirection(t *testing.T) {
+	city1 := &city{name: "City1"}
+	city2 := &city{name: "City2"}
+	city1.neighbors = map[direction]*city{north: city2}
+	city2.neighbors = map[direction]*city{south: city1}
+	alien := &alien{name: 1, currentCity: city1, strategy: MovementFunc(func(Surroundings, *rand.Rand) string {
+		return "west"
+	})}
+
+	moved, err := alien.move(nil, rand.New(rand.NewSource(1)))
+	assert.EqualError(t, err, "movement strategy of alien 1 picked direction 'west', but city 'City1' has no road in that direction")
+	assert.False(t, moved)
+	assert.Equal(t, city1, alien.currentCity, "expected alien to not move")
+}
+
 func TestAlien_goToCity(t *testing.T) {
 	alien := &alien{name: 1, currentCity: &city{}}
 	city := &city{name: "City1"}
@@ -0,0 +1,97 @@
+package simulation
+
+import (
+	"fmt"
+	"io"
+)
+
+// MapStats contains statistics about a map
+type MapStats struct {
+	Cities int
+	// Roads is the number of roads, a road between two cities is counted once
+	Roads int
+	// OneWayRoads is the number of roads that can only be travelled in one
+	// direction, they are included in Roads
+	OneWayRoads int
+	// IsolatedCities is the number of cities without any roads
+	IsolatedCities int
+	// Components is the number of groups of cities connected to each other,
+	// ignoring the direction of one-way roads. An isolated city is a
+	// component on its own
+	Components int
+	// LargestComponent is the number of cities in the largest component
+	LargestComponent int
+	// MaxRoads is the highest number of roads of a single city
+	MaxRoads int
+}
+
+// AnalyzeMap parses the map from input and returns statistics about it. Only
+// the options affecting how the map is parsed are used, as for ValidateMap
+func AnalyzeMap(input io.Reader, opts ...Option) (MapStats, error) {
+	m, err := parseInput(input, parseOptions(opts))
+	if err != nil {
+		return MapStats{}, fmt.Errorf("failed to parse input: %w", err)
+	}
+	cities := m.cities
+
+	sim := &Simulation{cities: cities}
+	stats := MapStats{Cities: len(cities)}
+	visited := make(map[string]bool, len(cities))
+
+	for _, cityName := range sim.sortedCityNames() {
+		c := cities[cityName]
+
+		twoWayRoads := 0
+		for _, d := range c.directions() {
+			if c.oneWay[d] {
+				stats.OneWayRoads++
+			} else {
+				twoWayRoads++
+			}
+		}
+		stats.Roads += twoWayRoads
+		if len(c.neighbors) == 0 && len(c.inbound) == 0 {
+			stats.IsolatedCities++
+		}
+		if len(c.neighbors) > stats.MaxRoads {
+			stats.MaxRoads = len(c.neighbors)
+		}
+
+		if visited[cityName] {
+			continue
+		}
+
+		// breadth-first search to find all cities of the component
+		stats.Components++
+		size := 0
+		queue := []*city{c}
+		visited[cityName] = true
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			size++
+
+			visit := func(neighbor *city) {
+				if !visited[neighbor.name] {
+					visited[neighbor.name] = true
+					queue = append(queue, neighbor)
+				}
+			}
+			for _, d := range current.directions() {
+				visit(current.neighbors[d])
+			}
+			for _, src := range current.inbound {
+				visit(src)
+			}
+		}
+
+		if size > stats.LargestComponent {
+			stats.LargestComponent = size
+		}
+	}
+
+	// each road in both directions is listed by both of its cities
+	stats.Roads = stats.Roads/2 + stats.OneWayRoads
+
+	return stats, nil
+}
@@ -0,0 +1,53 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeMap(t *testing.T) {
+	input := `Foo north=Bar east=Baz
+Bar south=Foo
+Baz west=Foo north=Qux
+Qux south=Baz
+Fex east=Jaz
+Jaz west=Fex
+Vex`
+
+	stats, err := AnalyzeMap(strings.NewReader(input))
+	assert.NoError(t, err, "expected no error when analyzing map")
+	assert.Equal(t, MapStats{
+		Cities:           7,
+		Roads:            4,
+		IsolatedCities:   1,
+		Components:       3,
+		LargestComponent: 4,
+		MaxRoads:         2,
+	}, stats)
+}
+
+func TestAnalyzeMap_oneWayRoads(t *testing.T) {
+	input := `Foo north->Bar east=Baz
+Bar
+Baz west=Foo
+Qux east->Qux`
+
+	stats, err := AnalyzeMap(strings.NewReader(input))
+	assert.NoError(t, err, "expected no error when analyzing map")
+	assert.Equal(t, MapStats{
+		Cities:           4,
+		Roads:            3,
+		OneWayRoads:      2,
+		IsolatedCities:   0,
+		Components:       2,
+		LargestComponent: 3,
+		MaxRoads:         2,
+	}, stats)
+}
+
+func TestAnalyzeMap_invalidInput(t *testing.T) {
+	_, err := AnalyzeMap(strings.NewReader("A north=B"))
+	assert.EqualError(t, err, "failed to parse input: unknown neighbor city 'B' in direction 'north' for city 'A'")
+}
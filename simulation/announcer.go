@@ -0,0 +1,77 @@
+package simulation
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// announcer is an observer writing an announcement for each destroyed city in
+// the format specified by the task description:
+//
+//	Bar has been destroyed by alien 10 and alien 34!
+//
+// and for each battle a city survived, depending on the battle rule:
+//
+//	Bar survived a battle between alien 10 and alien 34, alien 34 survived!
+//
+// and for each crossing on a road the aliens didn't pass:
+//
+//	alien 10 and alien 34 met on the road between Bar and Foo, alien 34 survived!
+//	alien 10 and alien 34 met on the road between Bar and Foo and turned back!
+type announcer struct {
+	w io.Writer
+}
+
+// WithAnnouncements writes an announcement to w each time a city is destroyed,
+// e.g. "Bar has been destroyed by alien 10 and alien 34!", or survives a
+// battle
+func WithAnnouncements(w io.Writer) Option {
+	return func(s *Simulation) {
+		s.Subscribe(&announcer{w: w})
+	}
+}
+
+func (a *announcer) OnEvent(e Event) {
+	switch e := e.(type) {
+	case CityDestroyed:
+		fmt.Fprintf(a.w, "%s has been destroyed by %s!\n", e.City, joinAliens(e.Aliens))
+	case BattleFought:
+		if e.CityDestroyed {
+			// announced by CityDestroyed
+			return
+		}
+		fmt.Fprintf(a.w, "%s survived a battle between %s, %s survived!\n", e.City, joinAliens(e.Aliens), joinSurvivors(e.Survivors))
+	case AliensCrossed:
+		switch e.Outcome {
+		case CrossingBattle:
+			fmt.Fprintf(a.w, "%s met on the road between %s and %s, %s survived!\n", joinAliens(e.Aliens), e.Cities[0], e.Cities[1], joinSurvivors(e.Survivors))
+		case CrossingBounce:
+			fmt.Fprintf(a.w, "%s met on the road between %s and %s and turned back!\n", joinAliens(e.Aliens), e.Cities[0], e.Cities[1])
+		}
+	}
+}
+
+// joinSurvivors returns the survivors of a battle as an enumeration, or "no
+// alien" if there is none
+func joinSurvivors(survivors []int) string {
+	if len(survivors) == 0 {
+		return "no alien"
+	}
+	return joinAliens(survivors)
+}
+
+// joinAliens returns the aliens as an enumeration, e.g. "alien 1, alien 2
+// and alien 3"
+func joinAliens(aliens []int) string {
+	names := make([]string, len(aliens))
+	for i, a := range aliens {
+		names[i] = fmt.Sprintf("alien %d", a)
+	}
+
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
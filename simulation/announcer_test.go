@@ -0,0 +1,49 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnouncer_OnEvent(t *testing.T) {
+	builder := &strings.Builder{}
+	a := &announcer{w: builder}
+
+	a.OnEvent(AlienMoved{Iteration: 1, Alien: 1, From: "Foo", To: "Bar"})
+	a.OnEvent(CityDestroyed{Iteration: 1, City: "Bar", Aliens: []int{10, 34}})
+	a.OnEvent(BattleFought{Iteration: 2, City: "Foo", Aliens: []int{1, 2, 3}, CityDestroyed: true})
+	a.OnEvent(CityDestroyed{Iteration: 2, City: "Foo", Aliens: []int{1, 2, 3}})
+	a.OnEvent(BattleFought{Iteration: 3, City: "Qux", Aliens: []int{4, 5}, Survivors: []int{5}})
+	a.OnEvent(BattleFought{Iteration: 3, City: "Baz", Aliens: []int{6, 7}})
+	a.OnEvent(AliensCrossed{Iteration: 4, Cities: [2]string{"Bar", "Foo"}, Aliens: []int{8, 9}, Survivors: []int{9}, Outcome: CrossingBattle})
+	a.OnEvent(AliensCrossed{Iteration: 4, Cities: [2]string{"Baz", "Qux"}, Aliens: []int{10, 11}, Outcome: CrossingBattle})
+	a.OnEvent(AliensCrossed{Iteration: 5, Cities: [2]string{"Bar", "Foo"}, Aliens: []int{8, 9}, Survivors: []int{8, 9}, Outcome: CrossingBounce})
+	a.OnEvent(AliensCrossed{Iteration: 6, Cities: [2]string{"Bar", "Foo"}, Aliens: []int{8, 9}, Survivors: []int{8, 9}, Outcome: CrossingPass})
+
+	assert.Equal(t, `Bar has been destroyed by alien 10 and alien 34!
+Foo has been destroyed by alien 1, alien 2 and alien 3!
+Qux survived a battle between alien 4 and alien 5, alien 5 survived!
+Baz survived a battle between alien 6 and alien 7, no alien survived!
+alien 8 and alien 9 met on the road between Bar and Foo, alien 9 survived!
+alien 10 and alien 11 met on the road between Baz and Qux, no alien survived!
+alien 8 and alien 9 met on the road between Bar and Foo and turned back!
+`, builder.String())
+}
+
+func TestJoinAliens(t *testing.T) {
+	tests := []struct {
+		input    []int
+		expected string
+	}{
+		{nil, ""},
+		{[]int{1}, "alien 1"},
+		{[]int{1, 2}, "alien 1 and alien 2"},
+		{[]int{1, 2, 3}, "alien 1, alien 2 and alien 3"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, joinAliens(test.input))
+	}
+}
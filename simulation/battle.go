@@ -0,0 +1,164 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// DefaultBattleRule is the name of the battle rule used when none is set, all
+// aliens in the battle are killed and the city is destroyed
+const DefaultBattleRule = "destroy-all"
+
+// DefaultMinCombatants is the number of aliens in a city needed for a battle
+// when none is set
+const DefaultMinCombatants = 2
+
+// DefaultStrength is the strength of an alien when none is set
+const DefaultStrength = 1.0
+
+// Combatant is an alien taking part in a battle
+type Combatant struct {
+	Alien    int
+	Strength float64
+}
+
+// BattleOutcome is the outcome of a battle decided by a BattleRule
+type BattleOutcome struct {
+	// Survivors contains the aliens surviving the battle, all other
+	// combatants are killed
+	Survivors []int
+	// CityDestroyed is set if the city is destroyed in the battle, there
+	// must be no survivors then
+	CityDestroyed bool
+}
+
+// BattleRule decides the outcome of a battle. It is asked for every city with
+// at least as many aliens as set by WithMinCombatants
+type BattleRule interface {
+	// Fight returns the outcome of a battle between the combatants in city,
+	// ordered by the time they arrived in the city. Aliens crossing each
+	// other on a road with CrossingBattle fight with city set to the names
+	// of both cities joined by '-', ordered by name. rnd is the random source
+	// of the simulation, it must be the only source of randomness to keep
+	// simulations reproducible
+	Fight(city string, combatants []Combatant, rnd *rand.Rand) BattleOutcome
+}
+
+// BattleFunc is an adapter to allow the use of ordinary functions as battle
+// rules
+type BattleFunc func(city string, combatants []Combatant, rnd *rand.Rand) BattleOutcome
+
+// Fight calls f(city, combatants, rnd)
+func (f BattleFunc) Fight(city string, combatants []Combatant, rnd *rand.Rand) BattleOutcome {
+	return f(city, combatants, rnd)
+}
+
+var battleRules = newRegistry[BattleRule]("battle rule")
+
+func init() {
+	mustRegisterBattleRule(DefaultBattleRule, BattleFunc(destroyAllBattle))
+	mustRegisterBattleRule("last-alien-standing", BattleFunc(lastAlienStandingBattle))
+	mustRegisterBattleRule("strength-weighted", BattleFunc(strengthWeightedBattle))
+	mustRegisterBattleRule("city-survives", BattleFunc(citySurvivesBattle))
+}
+
+// RegisterBattleRule registers a battle rule under name, so it can be used
+// with WithBattleRule
+func RegisterBattleRule(name string, rule BattleRule) error {
+	if name != "" && rule == nil {
+		return fmt.Errorf("battle rule '%s' is nil", name)
+	}
+	return battleRules.register(name, rule)
+}
+
+// BattleRules returns the names of all registered battle rules in sorted
+// order
+func BattleRules() []string {
+	return battleRules.names()
+}
+
+// lookupBattleRule returns the battle rule registered under name, the default
+// rule is returned if name is empty
+func lookupBattleRule(name string) (BattleRule, error) {
+	if name == "" {
+		name = DefaultBattleRule
+	}
+
+	return battleRules.lookup(name)
+}
+
+func mustRegisterBattleRule(name string, rule BattleRule) {
+	if err := RegisterBattleRule(name, rule); err != nil {
+		panic(err)
+	}
+}
+
+// fight asks rule for the outcome of a battle between the aliens in place,
+// the name of a city or a road. The outcome is validated, survivors contains
+// the aliens surviving the battle
+func fight(rule BattleRule, place string, aliens []*alien, rnd *rand.Rand) (outcome BattleOutcome, survivors map[int]bool, err error) {
+	combatants := make([]Combatant, len(aliens))
+	isCombatant := make(map[int]bool, len(aliens))
+	for i, a := range aliens {
+		combatants[i] = Combatant{Alien: a.name, Strength: a.strength}
+		if a.strength == 0 {
+			combatants[i].Strength = DefaultStrength
+		}
+		isCombatant[a.name] = true
+	}
+
+	outcome = rule.Fight(place, combatants, rnd)
+	if outcome.CityDestroyed && len(outcome.Survivors) > 0 {
+		return outcome, nil, fmt.Errorf("battle rule let %s survive the destruction of %s", joinAliens(outcome.Survivors), place)
+	}
+
+	survivors = make(map[int]bool, len(outcome.Survivors))
+	for _, name := range outcome.Survivors {
+		if !isCombatant[name] {
+			return outcome, nil, fmt.Errorf("battle rule let alien %d survive the battle in %s, but it is not a combatant", name, place)
+		}
+		if survivors[name] {
+			return outcome, nil, fmt.Errorf("battle rule let alien %d survive the battle in %s twice", name, place)
+		}
+		survivors[name] = true
+	}
+
+	return outcome, survivors, nil
+}
+
+// destroyAllBattle kills all combatants and destroys the city
+func destroyAllBattle(string, []Combatant, *rand.Rand) BattleOutcome {
+	return BattleOutcome{CityDestroyed: true}
+}
+
+// lastAlienStandingBattle lets a random combatant survive, the city is not
+// destroyed
+func lastAlienStandingBattle(_ string, combatants []Combatant, rnd *rand.Rand) BattleOutcome {
+	survivor := combatants[rnd.Intn(len(combatants))]
+	return BattleOutcome{Survivors: []int{survivor.Alien}}
+}
+
+// strengthWeightedBattle lets a random combatant survive, the chance of each
+// combatant is proportional to its strength. The city is not destroyed
+func strengthWeightedBattle(_ string, combatants []Combatant, rnd *rand.Rand) BattleOutcome {
+	total := 0.0
+	for _, c := range combatants {
+		total += c.Strength
+	}
+
+	pick := rnd.Float64() * total
+	for _, c := range combatants {
+		if pick < c.Strength {
+			return BattleOutcome{Survivors: []int{c.Alien}}
+		}
+		pick -= c.Strength
+	}
+
+	// rounding errors may leave a tiny rest
+	return BattleOutcome{Survivors: []int{combatants[len(combatants)-1].Alien}}
+}
+
+// citySurvivesBattle kills all combatants, the city is not destroyed
+func citySurvivesBattle(string, []Combatant, *rand.Rand) BattleOutcome {
+	return BattleOutcome{}
+}
@@ -0,0 +1,228 @@
+package simulation
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBattleRules(t *testing.T) {
+	assert.Subset(t, BattleRules(), []string{"city-survives", "destroy-all", "last-alien-standing", "strength-weighted"})
+}
+
+func TestRegisterBattleRule(t *testing.T) {
+	spare := BattleFunc(func(string, []Combatant, *rand.Rand) BattleOutcome { return BattleOutcome{} })
+	t.Cleanup(func() { battleRules.unregister("test-spare") })
+	assert.NoError(t, RegisterBattleRule("test-spare", spare))
+
+	testCases := []struct {
+		name          string
+		ruleName      string
+		rule          BattleRule
+		expectedError string
+	}{
+		{"empty name", "", spare, "battle rule name must not be empty"},
+		{"nil rule", "test-nil", nil, "battle rule 'test-nil' is nil"},
+		{"already registered", "test-spare", spare, "battle rule 'test-spare' is already registered"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := RegisterBattleRule(tc.ruleName, tc.rule)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+
+	_, err := lookupBattleRule("unknown")
+	assert.EqualError(t, err, "unknown battle rule 'unknown'")
+}
+
+func TestBattleRule_builtins(t *testing.T) {
+	combatants := []Combatant{{Alien: 1, Strength: 1}, {Alien: 2, Strength: 1}, {Alien: 3, Strength: 1}}
+	rnd := rand.New(rand.NewSource(1))
+
+	rule, err := lookupBattleRule("")
+	require.NoError(t, err)
+	assert.Equal(t, BattleOutcome{CityDestroyed: true}, rule.Fight("Foo", combatants, rnd), "expected destroy-all to be the default")
+
+	rule, err = lookupBattleRule("city-survives")
+	require.NoError(t, err)
+	assert.Equal(t, BattleOutcome{}, rule.Fight("Foo", combatants, rnd))
+
+	rule, err = lookupBattleRule("last-alien-standing")
+	require.NoError(t, err)
+	survivors := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		outcome := rule.Fight("Foo", combatants, rnd)
+		assert.False(t, outcome.CityDestroyed)
+		require.Len(t, outcome.Survivors, 1)
+		survivors[outcome.Survivors[0]] = true
+	}
+	assert.Equal(t, map[int]bool{1: true, 2: true, 3: true}, survivors, "expected every combatant to survive sometimes")
+}
+
+func TestBattleRule_strengthWeighted(t *testing.T) {
+	rule, err := lookupBattleRule("strength-weighted")
+	require.NoError(t, err)
+
+	combatants := []Combatant{{Alien: 1, Strength: 1}, {Alien: 2, Strength: 3}, {Alien: 3, Strength: 0.000001}}
+	rnd := rand.New(rand.NewSource(1))
+
+	wins := make(map[int]int)
+	for i := 0; i < 4000; i++ {
+		outcome := rule.Fight("Foo", combatants, rnd)
+		assert.False(t, outcome.CityDestroyed)
+		require.Len(t, outcome.Survivors, 1)
+		wins[outcome.Survivors[0]]++
+	}
+	assert.InDelta(t, 1000, wins[1], 100, "expected alien 1 to win about a quarter of the battles")
+	assert.InDelta(t, 3000, wins[2], 100, "expected alien 2 to win about three quarters of the battles")
+	assert.Less(t, wins[3], 5, "expected the weak alien to almost never win")
+}
+
+func TestNewSimulation_battleOptions(t *testing.T) {
+	input := "A north=B\nB south=A"
+
+	testCases := []struct {
+		name          string
+		opts          []Option
+		expectedError string
+	}{
+		{"unknown rule", []Option{WithBattleRule("unknown")}, "unknown battle rule 'unknown'"},
+		{"too few combatants", []Option{WithMinCombatants(1)}, "the minimum number of combatants must be at least 2, got 1"},
+		{"strength of missing alien", []Option{WithAlienStrength(3, 2)}, "strength 2 set for alien 3, but there is no such alien"},
+		{"invalid strength", []Option{WithAlienStrength(1, 0)}, "strength of alien 1 must be greater than 0, got 0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewSimulation(strings.NewReader(input), 2, tc.opts...)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+
+	sim, err := NewSimulation(strings.NewReader(input), 2, WithAlienStrength(2, 2.5))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultStrength, sim.aliens[0].strength)
+	assert.Equal(t, 2.5, sim.aliens[1].strength)
+}
+
+func TestSimulation_Step_battleRules(t *testing.T) {
+	input := `{
+  "cities": [
+    {"name": "A", "roads": [{"direction": "east", "city": "B"}]},
+    {"name": "B", "roads": [{"direction": "west", "city": "A"}]}
+  ],
+  "placements": [{"alien": 1, "city": "A"}, {"alien": 2, "city": "A"}, {"alien": 3, "city": "A"}, {"alien": 4, "city": "B"}]
+}`
+	// aliens that have used up their moves fight where they were placed
+	newSimulation := func(opts ...Option) *Simulation {
+		sim, err := NewSimulation(strings.NewReader(input), MapAliens, append(opts, WithMaxMoves(1), WithSeed(1))...)
+		require.NoError(t, err)
+		for _, a := range sim.aliens {
+			a.moves = 1
+		}
+		return sim
+	}
+
+	sim := newSimulation(WithBattleRule("city-survives"))
+	result, err := sim.Step()
+	require.NoError(t, err)
+	assert.Equal(t, []Battle{{City: "A", Aliens: []int{1, 2, 3}}}, result.Battles)
+	assert.False(t, sim.cities["A"].isDestroyed(), "expected the city to survive the battle")
+	assert.Equal(t, SimStateOnlyOneAlienLeft, result.State)
+	assert.Equal(t, AlienResult{Alien: 1, Moves: 1, Visited: []string{"A"}, Fate: FateDead, FateIteration: 1, FateCity: "A"}, sim.Result().Aliens[0])
+
+	sim = newSimulation(WithBattleRule("last-alien-standing"))
+	result, err = sim.Step()
+	require.NoError(t, err)
+	require.Len(t, result.Battles, 1)
+	require.Len(t, result.Battles[0].Survivors, 1)
+	assert.Equal(t, SimStateMaxIterationsReached, result.State, "expected the survivor and alien 4 to be able to meet")
+
+	sim = newSimulation(WithMinCombatants(4))
+	result, err = sim.Step()
+	require.NoError(t, err)
+	assert.Empty(t, result.Battles, "expected no battle with fewer than 4 aliens in a city")
+	assert.Equal(t, SimStateMaxIterationsReached, result.State)
+
+	sim = newSimulation(WithMinCombatants(3), WithBattleRule("last-alien-standing"))
+	result, err = sim.Step()
+	require.NoError(t, err)
+	assert.Len(t, result.Battles, 1)
+	assert.Equal(t, SimStateTooFewAliensLeft, result.State)
+}
+
+func TestSimulation_checkEndState_minCombatants(t *testing.T) {
+	// A -> B <- C, D -> E <- F
+	input := "A east->B\nB\nC west->B\nD east->E\nE\nF west->E"
+
+	testCases := []struct {
+		name          string
+		placements    string
+		minCombatants int
+		expected      SimState
+	}{
+		{"two can meet", "A C", 2, SimStateRunning},
+		{"two cannot meet", "A D", 2, SimStateAliveAliensDisconnected},
+		{"three can meet", "A B C", 3, SimStateRunning},
+		{"only two of three can meet", "A C D", 3, SimStateAliveAliensDisconnected},
+		{"three of four can meet", "A D E F", 3, SimStateRunning},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cities := strings.Fields(tc.placements)
+			sim, err := NewSimulation(strings.NewReader(input), len(cities), WithMinCombatants(tc.minCombatants), WithSeed(1))
+			require.NoError(t, err)
+
+			// place the aliens by hand, before any battle
+			for _, c := range sim.cities {
+				c.visitingAliens = nil
+			}
+			for i, a := range sim.aliens {
+				a.currentCity = sim.cities[cities[i]]
+				a.currentCity.visitingAliens = append(a.currentCity.visitingAliens, a)
+			}
+
+			assert.Equal(t, tc.expected, sim.checkEndState())
+		})
+	}
+}
+
+func TestSimulation_Run_everyBattleRule(t *testing.T) {
+	input, err := os.ReadFile("../testdata/input.txt")
+	require.NoError(t, err)
+
+	for _, rule := range []string{"destroy-all", "last-alien-standing", "strength-weighted", "city-survives"} {
+		for _, min := range []int{2, 3} {
+			for seed := int64(0); seed < 20; seed++ {
+				sim, err := NewSimulation(strings.NewReader(string(input)), 6,
+					WithBattleRule(rule), WithMinCombatants(min), WithAlienStrength(1, 5), WithSeed(seed),
+				)
+				require.NoError(t, err)
+
+				state, err := sim.Run()
+				require.NoError(t, err, "rule %s, min %d, seed %d", rule, min, seed)
+				assert.NotEqual(t, SimStateRunning, state)
+
+				alive := 0
+				for _, a := range sim.Result().Aliens {
+					if a.Fate != FateDead {
+						alive++
+					}
+				}
+				switch state {
+				case SimStateOnlyOneAlienLeft:
+					assert.Equal(t, 1, alive)
+				case SimStateTooFewAliensLeft:
+					assert.True(t, alive > 1 && alive < min, "expected 1 < %d < %d", alive, min)
+				}
+			}
+		}
+	}
+}
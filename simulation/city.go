@@ -2,7 +2,7 @@ package simulation
 
 import (
 	"fmt"
-	"log"
+	"math/rand"
 	"strings"
 
 	"golang.org/x/exp/maps"
@@ -11,6 +11,7 @@ import (
 
 type direction string
 
+// the directions of the default vocabulary
 const (
 	north direction = "north"
 	east  direction = "east"
@@ -18,70 +19,133 @@ const (
 	west  direction = "west"
 )
 
-func (d direction) opposite() direction {
-	switch d {
-	case north:
-		return south
-	case east:
-		return west
-	case south:
-		return north
-	case west:
-		return east
-	}
-	return ""
-}
-
-func (d direction) isValid() bool {
-	return d == north || d == east || d == south || d == west
-}
-
 type city struct {
-	name           string
-	neighbors      map[direction]*city
+	name      string
+	neighbors map[direction]*city
+	// oneWay contains the directions of the roads that can only be travelled
+	// away from the city, inbound contains the cities with one-way roads to
+	// the city
+	oneWay  map[direction]bool
+	inbound []*city
+	// vocab is the vocabulary of the map the city is part of, the default
+	// vocabulary is used when nil
+	vocab *vocabulary
+	// attributes are the attributes of the city in a JSON map, e.g.
+	// "population", they are nil for maps in the line format
+	attributes     map[string]any
 	visitingAliens []*alien
 	// use flag to differentiate between a destroyed and isolated (all
 	// neighbors are destroyed) city
 	destroyed bool
+	// destroyedAt and destroyedBy record the iteration in which the city was
+	// destroyed and the aliens that destroyed it
+	destroyedAt int
+	destroyedBy []int
+}
+
+// roads returns the roads of the city ordered by direction
+func (c *city) roads() []Road {
+	roads := make([]Road, 0, len(c.neighbors))
+	for _, d := range c.directions() {
+		roads = append(roads, Road{Direction: string(d), City: c.neighbors[d].name, OneWay: c.oneWay[d]})
+	}
+	return roads
+}
+
+// directions returns the directions of the city's roads in sorted order, it
+// should be used whenever the roads are traversed to keep the simulation
+// deterministic
+func (c *city) directions() []direction {
+	directions := maps.Keys(c.neighbors)
+	slices.Sort(directions)
+	return directions
 }
 
+// opposite returns the opposite of direction d in the vocabulary of the city
+func (c *city) opposite(d direction) direction {
+	if c.vocab == nil {
+		return compass.opposite(d)
+	}
+	return c.vocab.opposite(d)
+}
+
+// destroy destroys the city and removes all roads from and to it. Roads in
+// both directions must have a reverse road, one-way roads may have one
 func (c *city) destroy() error {
-	for d, neighbor := range c.neighbors {
-		city, ok := neighbor.neighbors[d.opposite()]
-		if !ok || city != c {
+	for _, d := range c.directions() {
+		// the road may be gone already if it was the reverse road of a road
+		// leading back to the city itself
+		neighbor, ok := c.neighbors[d]
+		if !ok {
+			continue
+		}
+		city, ok := neighbor.neighbors[c.opposite(d)]
+		if ok && city == c {
+			neighbor.removeRoad(c.opposite(d))
+		} else if !c.oneWay[d] {
 			return fmt.Errorf("neighbor city %s has no road in direction %s to %s", neighbor.name, d, c.name)
 		}
 
-		delete(neighbor.neighbors, d.opposite())
-		delete(c.neighbors, d)
+		c.removeRoad(d)
 	}
 
+	// remove the remaining one-way roads leading to the city
+	for _, src := range c.inbound {
+		for _, d := range src.directions() {
+			if src.neighbors[d] == c {
+				src.removeRoad(d)
+			}
+		}
+	}
+	c.inbound = nil
+
 	c.destroyed = true
 
 	return nil
 }
 
+// removeRoad removes the road in direction d from the city
+func (c *city) removeRoad(d direction) {
+	delete(c.neighbors, d)
+	delete(c.oneWay, d)
+}
+
 func (c *city) isDestroyed() bool {
 	return c.destroyed
 }
 
-// battle simulate a battle between at least 2 aliens in the city. All aliens
-// are killed and the city is destroyed in the process
-func (c *city) battle() error {
-	if len(c.visitingAliens) < 2 {
-		return nil
+// battle simulates a battle between the aliens in the city if there are at
+// least min of them, rule decides which aliens survive and if the city is
+// destroyed in the process. The battle and the killed aliens are returned, if
+// there was no battle the returned battle is nil
+func (c *city) battle(rule BattleRule, min int, rnd *rand.Rand) (*Battle, []*alien, error) {
+	if len(c.visitingAliens) < min {
+		return nil, nil, nil
 	}
 
-	alienNames := make([]string, len(c.visitingAliens))
-	for i, a := range c.visitingAliens {
-		a.die()
-		alienNames[i] = a.string()
+	outcome, survivors, err := fight(rule, c.name, c.visitingAliens, rnd)
+	if err != nil {
+		return nil, nil, err
 	}
-	c.visitingAliens = nil
 
-	log.Printf("city %s has been destroyed by %s", c.name, strings.Join(alienNames, " and "))
+	battle := &Battle{City: c.name, CityDestroyed: outcome.CityDestroyed}
+	var fallen, remaining []*alien
+	for _, a := range c.visitingAliens {
+		battle.Aliens = append(battle.Aliens, a.name)
+		if survivors[a.name] {
+			battle.Survivors = append(battle.Survivors, a.name)
+			remaining = append(remaining, a)
+			continue
+		}
+		a.die()
+		fallen = append(fallen, a)
+	}
+	c.visitingAliens = remaining
 
-	return c.destroy()
+	if outcome.CityDestroyed {
+		return battle, fallen, c.destroy()
+	}
+	return battle, fallen, nil
 }
 
 func (c *city) removeAlien(a *alien) {
@@ -93,8 +157,9 @@ func (c *city) removeAlien(a *alien) {
 	}
 }
 
-// reachableFrom returns true if the city is reachable from the destination. It
-// uses a recursive depth-first search for the node traversal
+// reachableFrom returns true if the destination is reachable from the city,
+// following roads in their direction only. It uses a recursive depth-first
+// search for the node traversal, visited contains all cities searched
 func (c *city) reachableFrom(dst *city, visited map[string]bool) bool {
 	if c == dst {
 		return true
@@ -102,7 +167,8 @@ func (c *city) reachableFrom(dst *city, visited map[string]bool) bool {
 
 	visited[c.name] = true
 
-	for _, neighbor := range c.neighbors {
+	for _, d := range c.directions() {
+		neighbor := c.neighbors[d]
 		if !visited[neighbor.name] && neighbor.reachableFrom(dst, visited) {
 			return true
 		}
@@ -111,19 +177,72 @@ func (c *city) reachableFrom(dst *city, visited map[string]bool) bool {
 	return false
 }
 
+// canMeet returns true if aliens in at least min of the cities can end up in
+// the same city, following roads in their direction only. A city is passed
+// once per alien, so aliens in the same city count separately. The cities
+// reachable from each city are searched breadth-first, the search stops as
+// soon as a city has been reached from min cities
+func canMeet(cities []*city, min int) bool {
+	// reached is the number of searches that reached a city, search is the
+	// last search that visited it. Searches are numbered from 1, so the zero
+	// mark belongs to a city no search has visited
+	type mark struct {
+		reached int
+		search  int
+	}
+	marks := make(map[*city]mark)
+
+	var queue []*city
+	for i, start := range cities {
+		search := i + 1
+		queue = queue[:0]
+		// visit marks c as reached by the search and queues it, it returns
+		// true once c has been reached by enough searches
+		visit := func(c *city) bool {
+			m := marks[c]
+			if m.search == search {
+				return false
+			}
+			m.search = search
+			m.reached++
+			marks[c] = m
+			queue = append(queue, c)
+			return m.reached >= min
+		}
+
+		if visit(start) {
+			return true
+		}
+		for head := 0; head < len(queue); head++ {
+			// the order of the roads doesn't matter, so they are not sorted
+			for _, neighbor := range queue[head].neighbors {
+				if visit(neighbor) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
 // string returns a string representation of the city and its neighbors
-// the "roads" are sorted by direction to make the output deterministic
+// the "roads" are sorted by direction to make the output deterministic. Names
+// are quoted if needed so the output can be parsed again
 func (c *city) string() string {
 	builder := strings.Builder{}
-	builder.WriteString(c.name)
+	builder.WriteString(quoteName(c.name))
 
 	if len(c.neighbors) > 0 {
-		directions := maps.Keys(c.neighbors)
-		slices.Sort(directions)
+		directions := c.directions()
 
 		roads := make([]string, len(directions))
 		for i, dir := range directions {
-			roads[i] = string(dir) + "=" + c.neighbors[direction(dir)].name
+			separator := twoWaySeparator
+			if c.oneWay[dir] {
+				separator = oneWaySeparator
+			}
+			roads[i] = string(dir) + separator + quoteName(c.neighbors[dir].name)
 		}
 
 		builder.WriteString(" ")
@@ -1,46 +1,24 @@
 package simulation
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
-func TestDirection_opposite(t *testing.T) {
-	tests := []struct {
-		input    direction
-		expected direction
-	}{
-		{north, south},
-		{south, north},
-		{east, west},
-		{west, east},
-		{direction("invalid"), direction("")},
-	}
-
-	for _, test := range tests {
-		actual := test.input.opposite()
-		assert.Equal(t, test.expected, actual)
-	}
-}
-
-func TestDirection_isValid(t *testing.T) {
-	tests := []struct {
-		input    direction
-		expected bool
-	}{
-		{north, true},
-		{south, true},
-		{east, true},
-		{west, true},
-		{"", false},
-		{"foo", false},
+func TestCity_directions(t *testing.T) {
+	city := &city{
+		name: "City1",
+		neighbors: map[direction]*city{
+			west:  {name: "City2"},
+			north: {name: "City3"},
+			east:  {name: "City4"},
+		},
 	}
 
-	for _, test := range tests {
-		actual := test.input.isValid()
-		assert.Equal(t, test.expected, actual)
-	}
+	assert.Equal(t, []direction{east, north, west}, city.directions())
 }
 
 func TestCity_destroy(t *testing.T) {
@@ -65,7 +43,7 @@ func TestCity_destroy(t *testing.T) {
 	assert.True(t, city1.isDestroyed(), "expected city1 to be destroyed")
 
 	for d, n := range city1.neighbors {
-		_, ok := n.neighbors[d.opposite()]
+		_, ok := n.neighbors[city1.opposite(d)]
 		assert.Falsef(t, ok, "expected neighbor %s of city1 to have no road in direction %s", n.name, d)
 	}
 }
@@ -84,6 +62,33 @@ func TestCity_destroy_invalidNeighborRoad(t *testing.T) {
 	assert.EqualError(t, err, "neighbor city City2 has no road in direction north to City1")
 }
 
+func TestCity_destroy_oneWayRoads(t *testing.T) {
+	city1 := &city{name: "City1"}
+	city2 := &city{name: "City2"}
+	city3 := &city{name: "City3"}
+	city4 := &city{name: "City4"}
+	// City1 -> City2 -> City3 -> City4, City2 north->City1 is the reverse of
+	// City1's road to City2
+	city1.neighbors = map[direction]*city{south: city2}
+	city1.oneWay = map[direction]bool{south: true}
+	city2.neighbors = map[direction]*city{north: city1, east: city3}
+	city2.oneWay = map[direction]bool{north: true, east: true}
+	city2.inbound = []*city{city1}
+	city3.neighbors = map[direction]*city{east: city4}
+	city3.oneWay = map[direction]bool{east: true}
+	city3.inbound = []*city{city2}
+	city4.inbound = []*city{city3}
+	city1.inbound = []*city{city2}
+
+	err := city2.destroy()
+	assert.NoError(t, err, "expected no error when destroying city2")
+	assert.True(t, city2.isDestroyed(), "expected city2 to be destroyed")
+	assert.Empty(t, city2.neighbors, "expected outgoing roads to be removed")
+	assert.Empty(t, city1.neighbors, "expected incoming road from city1 to be removed")
+	assert.Empty(t, city1.oneWay, "expected one-way flag of the removed road to be removed")
+	assert.Equal(t, map[direction]*city{east: city4}, city3.neighbors, "expected unrelated roads to be kept")
+}
+
 func TestCity_battle(t *testing.T) {
 	city := &city{name: "City1"}
 	alien1 := &alien{name: 1, currentCity: city}
@@ -91,8 +96,10 @@ func TestCity_battle(t *testing.T) {
 	alien3 := &alien{name: 3, currentCity: city}
 	city.visitingAliens = []*alien{alien1, alien2, alien3}
 
-	err := city.battle()
+	battle, fallen, err := city.battle(BattleFunc(destroyAllBattle), 2, rand.New(rand.NewSource(1)))
 	assert.NoError(t, err, "expected no error when battling city")
+	assert.Equal(t, &Battle{City: "City1", Aliens: []int{1, 2, 3}, CityDestroyed: true}, battle)
+	assert.Equal(t, []*alien{alien1, alien2, alien3}, fallen, "expected all aliens to fall in the battle")
 
 	assert.True(t, city.isDestroyed(), "expected city to be destroyed after battle")
 	assert.True(t, alien1.isDead(), "expected alien1 to be dead after battle")
@@ -100,6 +107,38 @@ func TestCity_battle(t *testing.T) {
 	assert.True(t, alien3.isDead(), "expected alien3 to be dead after battle")
 }
 
+func TestCity_battle_singleAlien(t *testing.T) {
+	city := &city{name: "City1"}
+	alien1 := &alien{name: 1, currentCity: city}
+	city.visitingAliens = []*alien{alien1}
+
+	battle, fallen, err := city.battle(BattleFunc(destroyAllBattle), 2, rand.New(rand.NewSource(1)))
+	assert.NoError(t, err, "expected no error when battling city")
+	assert.Nil(t, battle, "expected no battle with a single alien")
+	assert.Empty(t, fallen)
+	assert.False(t, city.isDestroyed(), "expected city to not be destroyed")
+	assert.False(t, alien1.isDead(), "expected alien1 to be alive")
+}
+
+func TestCity_battle_selfRoad(t *testing.T) {
+	// Zox north=Zox south=Zox east=Bar, the roads leading back to Zox are
+	// each other's reverse road
+	zox := &city{name: "Zox"}
+	bar := &city{name: "Bar"}
+	zox.neighbors = map[direction]*city{north: zox, south: zox, east: bar}
+	bar.neighbors = map[direction]*city{west: zox}
+	alien1 := &alien{name: 1, currentCity: zox}
+	alien2 := &alien{name: 2, currentCity: zox}
+	zox.visitingAliens = []*alien{alien1, alien2}
+
+	battle, _, err := zox.battle(BattleFunc(destroyAllBattle), 2, rand.New(rand.NewSource(1)))
+	assert.NoError(t, err, "expected no error when battling a city with roads to itself")
+	assert.Equal(t, &Battle{City: "Zox", Aliens: []int{1, 2}, CityDestroyed: true}, battle)
+	assert.True(t, zox.isDestroyed(), "expected Zox to be destroyed after battle")
+	assert.Empty(t, zox.neighbors, "expected all roads of Zox to be removed")
+	assert.Empty(t, bar.neighbors, "expected the road from Bar to Zox to be removed")
+}
+
 func TestCity_reachableFrom(t *testing.T) {
 	a := &city{name: "A", neighbors: make(map[direction]*city)}
 	b := &city{name: "B", neighbors: make(map[direction]*city)}
@@ -132,6 +171,141 @@ func TestCity_reachableFrom(t *testing.T) {
 	}
 }
 
+func TestCanMeet(t *testing.T) {
+	// A -> B <- C, D -> E
+	a := &city{name: "A", neighbors: make(map[direction]*city), oneWay: map[direction]bool{east: true}}
+	b := &city{name: "B", neighbors: make(map[direction]*city)}
+	c := &city{name: "C", neighbors: make(map[direction]*city), oneWay: map[direction]bool{west: true}}
+	d := &city{name: "D", neighbors: make(map[direction]*city), oneWay: map[direction]bool{north: true}}
+	e := &city{name: "E", neighbors: make(map[direction]*city)}
+
+	a.neighbors[east] = b
+	c.neighbors[west] = b
+	d.neighbors[north] = e
+
+	testCases := []struct {
+		name     string
+		cities   []*city
+		min      int
+		expected bool
+	}{
+		{"same city", []*city{a, a}, 2, true},
+		{"along one-way road", []*city{a, b}, 2, true},
+		{"against one-way road", []*city{b, a}, 2, true},
+		{"both reach the same city", []*city{a, c}, 2, true},
+		{"only one reaches the other", []*city{d, e}, 2, true},
+		{"not connected", []*city{a, d}, 2, false},
+		{"dead end", []*city{b, e}, 2, false},
+		{"three reach the same city", []*city{a, b, c}, 3, true},
+		{"too few reach the same city", []*city{a, c, d, e}, 3, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, canMeet(tc.cities, tc.min), "expected aliens in the cities to meet or not")
+		})
+	}
+}
+
+func TestCanMeet_longChain(t *testing.T) {
+	// the aliens at both ends of a long chain of cities can meet
+	cities := make([]*city, 100000)
+	for i := range cities {
+		cities[i] = &city{name: fmt.Sprint(i), neighbors: make(map[direction]*city)}
+		if i > 0 {
+			cities[i-1].neighbors[east] = cities[i]
+			cities[i].neighbors[west] = cities[i-1]
+		}
+	}
+
+	assert.True(t, canMeet([]*city{cities[0], cities[len(cities)-1]}, 2))
+	assert.False(t, canMeet([]*city{cities[0]}, 2))
+}
+
+func TestCity_battle_rules(t *testing.T) {
+	testCases := []struct {
+		name              string
+		rule              BattleRule
+		min               int
+		expectedBattle    *Battle
+		expectedFallen    []int
+		expectedError     string
+		expectedDestroyed bool
+	}{
+		{
+			name:           "too few combatants",
+			rule:           BattleFunc(destroyAllBattle),
+			min:            4,
+			expectedBattle: nil,
+		},
+		{
+			name:           "city survives",
+			rule:           BattleFunc(citySurvivesBattle),
+			min:            3,
+			expectedBattle: &Battle{City: "City1", Aliens: []int{1, 2, 3}},
+			expectedFallen: []int{1, 2, 3},
+		},
+		{
+			name: "survivor",
+			rule: BattleFunc(func(string, []Combatant, *rand.Rand) BattleOutcome {
+				return BattleOutcome{Survivors: []int{2}}
+			}),
+			min:            2,
+			expectedBattle: &Battle{City: "City1", Aliens: []int{1, 2, 3}, Survivors: []int{2}},
+			expectedFallen: []int{1, 3},
+		},
+		{
+			name: "survivor in a destroyed city",
+			rule: BattleFunc(func(string, []Combatant, *rand.Rand) BattleOutcome {
+				return BattleOutcome{Survivors: []int{2}, CityDestroyed: true}
+			}),
+			min:           2,
+			expectedError: "battle rule let alien 2 survive the destruction of City1",
+		},
+		{
+			name: "survivor not in the battle",
+			rule: BattleFunc(func(string, []Combatant, *rand.Rand) BattleOutcome {
+				return BattleOutcome{Survivors: []int{4}}
+			}),
+			min:           2,
+			expectedError: "battle rule let alien 4 survive the battle in City1, but it is not a combatant",
+		},
+		{
+			name: "survivor twice",
+			rule: BattleFunc(func(string, []Combatant, *rand.Rand) BattleOutcome {
+				return BattleOutcome{Survivors: []int{1, 1}}
+			}),
+			min:           2,
+			expectedError: "battle rule let alien 1 survive the battle in City1 twice",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			city := &city{name: "City1"}
+			aliens := []*alien{{name: 1, currentCity: city}, {name: 2, currentCity: city}, {name: 3, currentCity: city}}
+			city.visitingAliens = append([]*alien(nil), aliens...)
+
+			battle, fallen, err := city.battle(tc.rule, tc.min, rand.New(rand.NewSource(1)))
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBattle, battle)
+			assert.False(t, city.isDestroyed(), "expected the city to survive")
+
+			var fallenNames []int
+			for _, a := range fallen {
+				assert.True(t, a.isDead(), "expected alien %d to be dead", a.name)
+				fallenNames = append(fallenNames, a.name)
+			}
+			assert.Equal(t, tc.expectedFallen, fallenNames)
+			assert.Len(t, city.visitingAliens, len(aliens)-len(fallen), "expected only the survivors to stay in the city")
+		})
+	}
+}
+
 func TestCity_string(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -158,6 +332,18 @@ func TestCity_string(t *testing.T) {
 			},
 			expected: "City1 east=City4 north=City2 south=City3 west=City5",
 		},
+		{
+			name: "with one-way roads",
+			city: &city{
+				name: "City1",
+				neighbors: map[direction]*city{
+					north: {name: "City2"},
+					south: {name: "City3"},
+				},
+				oneWay: map[direction]bool{south: true},
+			},
+			expected: "City1 north=City2 south->City3",
+		},
 	}
 
 	for _, tc := range testCases {
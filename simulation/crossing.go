@@ -0,0 +1,196 @@
+package simulation
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// CrossingOutcome decides what happens to aliens crossing each other on the
+// road between two cities with simultaneous moves, see WithSimultaneousMoves
+type CrossingOutcome string
+
+// crossing outcomes
+const (
+	// the crossing aliens fight a battle on the road following the battle
+	// rule if there are at least as many as needed for a battle, otherwise
+	// they pass each other. Survivors complete their move, a rule destroying
+	// the city kills all crossing aliens but no city is destroyed
+	CrossingBattle CrossingOutcome = "battle"
+	// the crossing aliens turn back and stay in the cities they came from
+	CrossingBounce CrossingOutcome = "bounce"
+	// the crossing aliens pass each other unharmed
+	CrossingPass CrossingOutcome = "pass"
+)
+
+// CrossingOutcomes returns the names of all crossing outcomes
+func CrossingOutcomes() []string {
+	return []string{string(CrossingBattle), string(CrossingBounce), string(CrossingPass)}
+}
+
+// Crossing describes aliens crossing each other on the road between two
+// cities with simultaneous moves
+type Crossing struct {
+	// Cities contains the cities at both ends of the road, ordered by name
+	Cities [2]string
+	// Aliens contains the crossing aliens ordered by name, Survivors the
+	// aliens alive after the crossing
+	Aliens    []int
+	Survivors []int
+	// Outcome is what happened to the aliens, CrossingPass if there were too
+	// few aliens for a battle
+	Outcome CrossingOutcome
+}
+
+// plannedMove is the move an alien picked with simultaneous moves, before it
+// is made
+type plannedMove struct {
+	alien *alien
+	to    *city
+}
+
+// moveSimultaneously lets every alien pick its move before any alien moves,
+// so all aliens see the world as it was at the start of the iteration. Then
+// the crossings are resolved and the remaining moves are made
+func (s *Simulation) moveSimultaneously(result *StepResult) error {
+	var planned []plannedMove
+	for _, a := range s.aliens {
+		if !a.hasMovesLeft(s.maxMoves) {
+			continue
+		}
+
+		to, err := a.plan(s.cities, s.rnd)
+		if err != nil {
+			return fmt.Errorf("failed to move alien: %w", err)
+		}
+		if to == nil {
+			a.stay()
+			continue
+		}
+		planned = append(planned, plannedMove{alien: a, to: to})
+	}
+
+	// group the moves by the cities at both ends of the road, a road is
+	// crossed if aliens move along it in both directions
+	byRoad := make(map[[2]string][]plannedMove)
+	for _, m := range planned {
+		road := roadBetween(m.alien.currentCity.name, m.to.name)
+		byRoad[road] = append(byRoad[road], m)
+	}
+	roads := maps.Keys(byRoad)
+	slices.SortFunc(roads, func(a, b [2]string) bool {
+		return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+	})
+
+	stopped := make(map[*alien]bool)
+	for _, road := range roads {
+		moves := byRoad[road]
+		if !isCrossed(road, moves) {
+			continue
+		}
+
+		crossing, err := s.cross(road, moves, stopped)
+		if err != nil {
+			return err
+		}
+		result.Crossings = append(result.Crossings, crossing)
+		s.emit(AliensCrossed{
+			Iteration: s.iteration,
+			Cities:    crossing.Cities,
+			Aliens:    crossing.Aliens,
+			Survivors: crossing.Survivors,
+			Outcome:   crossing.Outcome,
+		})
+	}
+
+	for _, m := range planned {
+		if stopped[m.alien] {
+			m.alien.stay()
+			continue
+		}
+
+		from := m.alien.currentCity
+		m.alien.goToCity(m.to)
+		m.alien.moves++
+
+		move := Move{Alien: m.alien.name, From: from.name, To: m.to.name}
+		result.Moves = append(result.Moves, move)
+		s.emit(AlienMoved{Iteration: s.iteration, Alien: move.Alien, From: move.From, To: move.To})
+	}
+
+	return nil
+}
+
+// cross resolves the crossing of the aliens moving along road in both
+// directions according to the crossing outcome. Aliens that don't complete
+// their move are added to stopped
+func (s *Simulation) cross(road [2]string, moves []plannedMove, stopped map[*alien]bool) (Crossing, error) {
+	crossing := Crossing{Cities: road, Outcome: s.crossing}
+	if crossing.Outcome == "" {
+		crossing.Outcome = CrossingBattle
+	}
+
+	aliens := make([]*alien, len(moves))
+	for i, m := range moves {
+		aliens[i] = m.alien
+		crossing.Aliens = append(crossing.Aliens, m.alien.name)
+	}
+
+	rule, min, rnd := s.battleRules()
+	if crossing.Outcome == CrossingBattle && len(aliens) < min {
+		crossing.Outcome = CrossingPass
+	}
+
+	switch crossing.Outcome {
+	case CrossingBattle:
+		_, survivors, err := fight(rule, road[0]+"-"+road[1], aliens, rnd)
+		if err != nil {
+			return crossing, fmt.Errorf("failed to simulate battle: %w", err)
+		}
+		for _, a := range aliens {
+			if survivors[a.name] {
+				crossing.Survivors = append(crossing.Survivors, a.name)
+				continue
+			}
+
+			// the alien dies on the road, in the city it came from
+			a.fateIteration = s.iteration
+			a.fateCity = a.currentCity.name
+			a.currentCity.removeAlien(a)
+			a.die()
+			stopped[a] = true
+		}
+	case CrossingBounce:
+		crossing.Survivors = crossing.Aliens
+		for _, a := range aliens {
+			stopped[a] = true
+		}
+	default:
+		crossing.Survivors = crossing.Aliens
+	}
+
+	return crossing, nil
+}
+
+// roadBetween returns the cities at both ends of a road ordered by name, so
+// moves in both directions along the road have the same key
+func roadBetween(a, b string) [2]string {
+	if b < a {
+		return [2]string{b, a}
+	}
+	return [2]string{a, b}
+}
+
+// isCrossed returns true if the moves go along road in both directions
+func isCrossed(road [2]string, moves []plannedMove) bool {
+	forward, backward := false, false
+	for _, m := range moves {
+		if m.alien.currentCity.name == road[0] {
+			forward = true
+		} else {
+			backward = true
+		}
+	}
+	return forward && backward
+}
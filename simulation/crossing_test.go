@@ -0,0 +1,151 @@
+package simulation
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossingOutcomes(t *testing.T) {
+	assert.Equal(t, []string{"battle", "bounce", "pass"}, CrossingOutcomes())
+}
+
+func TestSimulation_Step_simultaneous(t *testing.T) {
+	// alien 1 moves from B to C and alien 2 from C to B
+	t.Cleanup(func() {
+		movementStrategies.unregister("test-cross-east")
+		movementStrategies.unregister("test-cross-west")
+	})
+	require.NoError(t, RegisterMovementStrategy("test-cross-east", MovementFunc(func(Surroundings, *rand.Rand) string { return "east" })))
+	require.NoError(t, RegisterMovementStrategy("test-cross-west", MovementFunc(func(Surroundings, *rand.Rand) string { return "west" })))
+	input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "B"}, {"alien": 2, "city": "C"}`, 1)
+
+	testCases := []struct {
+		name              string
+		opts              []Option
+		expectedMoves     []Move
+		expectedCrossings []Crossing
+		// expectedCities contains the city of each alien after the step, an
+		// empty string for dead aliens
+		expectedCities []string
+	}{
+		{
+			name:           "in order",
+			expectedMoves:  []Move{{Alien: 1, From: "B", To: "C"}, {Alien: 2, From: "C", To: "B"}},
+			expectedCities: []string{"C", "B"},
+		},
+		{
+			name:              "battle",
+			opts:              []Option{WithSimultaneousMoves("")},
+			expectedCrossings: []Crossing{{Cities: [2]string{"B", "C"}, Aliens: []int{1, 2}, Outcome: CrossingBattle}},
+			expectedCities:    []string{"", ""},
+		},
+		{
+			name:              "battle with a survivor",
+			opts:              []Option{WithSimultaneousMoves(CrossingBattle), WithBattleRule("strength-weighted"), WithAlienStrength(2, 1e9)},
+			expectedMoves:     []Move{{Alien: 2, From: "C", To: "B"}},
+			expectedCrossings: []Crossing{{Cities: [2]string{"B", "C"}, Aliens: []int{1, 2}, Survivors: []int{2}, Outcome: CrossingBattle}},
+			expectedCities:    []string{"", "B"},
+		},
+		{
+			name:              "too few aliens for a battle",
+			opts:              []Option{WithSimultaneousMoves(CrossingBattle), WithMinCombatants(3)},
+			expectedMoves:     []Move{{Alien: 1, From: "B", To: "C"}, {Alien: 2, From: "C", To: "B"}},
+			expectedCrossings: []Crossing{{Cities: [2]string{"B", "C"}, Aliens: []int{1, 2}, Survivors: []int{1, 2}, Outcome: CrossingPass}},
+			expectedCities:    []string{"C", "B"},
+		},
+		{
+			name:              "bounce",
+			opts:              []Option{WithSimultaneousMoves(CrossingBounce)},
+			expectedCrossings: []Crossing{{Cities: [2]string{"B", "C"}, Aliens: []int{1, 2}, Survivors: []int{1, 2}, Outcome: CrossingBounce}},
+			expectedCities:    []string{"B", "C"},
+		},
+		{
+			name:              "pass",
+			opts:              []Option{WithSimultaneousMoves(CrossingPass)},
+			expectedMoves:     []Move{{Alien: 1, From: "B", To: "C"}, {Alien: 2, From: "C", To: "B"}},
+			expectedCrossings: []Crossing{{Cities: [2]string{"B", "C"}, Aliens: []int{1, 2}, Survivors: []int{1, 2}, Outcome: CrossingPass}},
+			expectedCities:    []string{"C", "B"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]Option{
+				WithAlienMovementStrategy(1, "test-cross-east"),
+				WithAlienMovementStrategy(2, "test-cross-west"),
+			}, tc.opts...)
+			sim, err := NewSimulation(strings.NewReader(input), MapAliens, opts...)
+			require.NoError(t, err)
+
+			result, err := sim.Step()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedMoves, result.Moves)
+			assert.Equal(t, tc.expectedCrossings, result.Crossings)
+			assert.Empty(t, result.Battles, "expected no battle in a city")
+			assert.Len(t, sim.SurvivedCities(), 4, "expected no city to be destroyed")
+
+			cities := make([]string, len(sim.aliens))
+			for i, a := range sim.aliens {
+				if !a.isDead() {
+					cities[i] = a.currentCity.name
+				}
+			}
+			assert.Equal(t, tc.expectedCities, cities)
+		})
+	}
+
+	_, err := NewSimulation(strings.NewReader(input), MapAliens, WithSimultaneousMoves("swap"))
+	assert.EqualError(t, err, "unknown crossing outcome: swap")
+}
+
+func TestSimulation_Step_simultaneousFates(t *testing.T) {
+	input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "B"}, {"alien": 2, "city": "C"}`, 1)
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens,
+		WithSimultaneousMoves(CrossingBattle),
+		WithMovementStrategy("seek-nearest-alien"),
+	)
+	require.NoError(t, err)
+
+	state, err := sim.Run()
+	require.NoError(t, err)
+	assert.Equal(t, SimStateAllAliensDeadOrTrapped, state)
+
+	// aliens killed on a road died in the city they came from
+	result := sim.Result()
+	assert.Equal(t, FateDead, result.Aliens[0].Fate)
+	assert.Equal(t, "B", result.Aliens[0].FateCity)
+	assert.Equal(t, "C", result.Aliens[1].FateCity)
+	assert.Equal(t, 1, result.Aliens[1].FateIteration)
+	assert.Empty(t, result.DestroyedCities)
+}
+
+func TestSimulation_Step_simultaneousOrder(t *testing.T) {
+	// aliens seeking each other from both ends of the chain. Moving in
+	// order, alien 2 sees alien 1 has moved to B and they meet in C. Moving
+	// at once, both move towards each other and meet on the road between B
+	// and C
+	input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "A"}, {"alien": 2, "city": "D"}`, 1)
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens, WithMovementStrategy("seek-nearest-alien"))
+	require.NoError(t, err)
+	_, err = sim.Run()
+	require.NoError(t, err)
+	assert.True(t, sim.cities["C"].isDestroyed(), "expected the aliens to meet in C")
+
+	sim, err = NewSimulation(strings.NewReader(input), MapAliens,
+		WithMovementStrategy("seek-nearest-alien"),
+		WithSimultaneousMoves(CrossingBattle),
+	)
+	require.NoError(t, err)
+	result, err := sim.Step()
+	require.NoError(t, err)
+	assert.Equal(t, []Move{{Alien: 1, From: "A", To: "B"}, {Alien: 2, From: "D", To: "C"}}, result.Moves)
+	result, err = sim.Step()
+	require.NoError(t, err)
+	assert.Equal(t, []Crossing{{Cities: [2]string{"B", "C"}, Aliens: []int{1, 2}, Outcome: CrossingBattle}}, result.Crossings)
+	assert.Equal(t, SimStateAllAliensDeadOrTrapped, result.State)
+}
@@ -0,0 +1,194 @@
+package simulation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DOTView selects the map rendered by WriteDOT
+type DOTView string
+
+// DOT views
+const (
+	// the map before the first iteration, with the aliens in the cities they
+	// were placed in
+	DOTInitial DOTView = "initial"
+	// the map after the last iteration, with the destroyed cities and the
+	// alive aliens
+	DOTFinal DOTView = "final"
+	// the final map drawn over the initial map, roads lost during the
+	// simulation are dashed
+	DOTOverlay DOTView = "overlay"
+)
+
+// dotEdge is a road drawn as an edge, a road in both directions is drawn as
+// a single edge
+type dotEdge struct {
+	from, to string
+	// fromDir is the direction of the road from the city at the tail, toDir
+	// the direction of the road back. toDir is empty for one-way roads
+	fromDir, toDir string
+}
+
+// key identifies the edge by the road from its tail
+func (e dotEdge) key() string {
+	return e.from + "\x00" + e.fromDir
+}
+
+// WriteDOT writes the world to w as a Graphviz DOT graph, e.g. to render it
+// with "dot -Tsvg". Roads are drawn as edges labelled with their direction at
+// each end, roads in both directions as a single edge with two arrow heads.
+// Destroyed cities are drawn dashed and grey with the aliens that destroyed
+// them, cities with trapped aliens are drawn orange
+func (s *Simulation) WriteDOT(w io.Writer, view DOTView) error {
+	var title string
+	switch view {
+	case DOTInitial:
+		title = "initial map"
+	case DOTFinal:
+		title = fmt.Sprintf("final map after %d iterations", s.iteration)
+	case DOTOverlay:
+		title = fmt.Sprintf("final map after %d iterations over the initial map", s.iteration)
+	default:
+		return fmt.Errorf("unknown DOT view: %s", view)
+	}
+	if s.metadata.Name != "" {
+		title = s.metadata.Name + ": " + title
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph world {")
+	fmt.Fprintf(bw, "  label=%s;\n", dotQuote(title))
+	fmt.Fprintln(bw, "  labelloc=t;")
+	fmt.Fprintln(bw, "  node [shape=box, style=rounded];")
+
+	for _, name := range s.sortedCityNames() {
+		fmt.Fprintf(bw, "  %s [%s];\n", dotQuote(name), s.dotCityAttributes(s.cities[name], view))
+	}
+
+	currentRoads := make(map[string][]Road, len(s.cities))
+	for name, c := range s.cities {
+		currentRoads[name] = c.roads()
+	}
+
+	finalEdges := s.dotEdges(currentRoads)
+	current := make(map[string]bool, len(finalEdges))
+	for _, e := range finalEdges {
+		current[e.key()] = true
+	}
+	edges := finalEdges
+	if view != DOTFinal {
+		edges = s.dotEdges(s.initialRoads)
+	}
+
+	for _, e := range edges {
+		attributes := []string{"taillabel=" + dotQuote(e.fromDir)}
+		if e.toDir != "" {
+			attributes = append(attributes, "headlabel="+dotQuote(e.toDir), "dir=both")
+		}
+		if view == DOTOverlay && !current[e.key()] {
+			attributes = append(attributes, "style=dashed", "color=gray")
+		}
+		fmt.Fprintf(bw, "  %s -> %s [%s];\n", dotQuote(e.from), dotQuote(e.to), strings.Join(attributes, ", "))
+	}
+
+	fmt.Fprintln(bw, "}")
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write DOT output: %w", err)
+	}
+
+	return nil
+}
+
+// dotCityAttributes returns the DOT attributes of the node of a city
+func (s *Simulation) dotCityAttributes(c *city, view DOTView) string {
+	lines := []string{c.name}
+	var attributes []string
+
+	if view == DOTInitial {
+		var placed []int
+		for _, a := range s.aliens {
+			if len(a.visited) > 0 && a.visited[0] == c.name {
+				placed = append(placed, a.name)
+			}
+		}
+		if len(placed) > 0 {
+			lines = append(lines, joinAliens(placed))
+		}
+		return "label=" + dotLabel(lines)
+	}
+
+	if c.isDestroyed() {
+		lines = append(lines,
+			"destroyed by "+joinAliens(c.destroyedBy),
+			fmt.Sprintf("in iteration %d", c.destroyedAt),
+		)
+		attributes = append(attributes, `style="rounded,dashed,filled"`, "fillcolor=lightgray", "fontcolor=gray40")
+	}
+
+	trapped := false
+	for _, a := range s.aliens {
+		if a.isDead() || a.currentCity != c {
+			continue
+		}
+		if a.isTrapped() {
+			trapped = true
+			lines = append(lines, fmt.Sprintf("alien %d (trapped)", a.name))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("alien %d", a.name))
+	}
+	if trapped {
+		attributes = append(attributes, "color=orange", "penwidth=2")
+	}
+
+	return strings.Join(append([]string{"label=" + dotLabel(lines)}, attributes...), ", ")
+}
+
+// dotEdges returns the edges for the roads of the cities, in city and
+// direction order. A road is paired with the road back if there is one
+func (s *Simulation) dotEdges(roads map[string][]Road) []dotEdge {
+	var edges []dotEdge
+	drawn := make(map[string]bool)
+	for _, name := range s.sortedCityNames() {
+		for _, r := range roads[name] {
+			e := dotEdge{from: name, to: r.City, fromDir: r.Direction}
+			if drawn[e.key()] {
+				continue
+			}
+			drawn[e.key()] = true
+
+			if !r.OneWay {
+				back := string(s.cities[name].opposite(direction(r.Direction)))
+				for _, b := range roads[r.City] {
+					reverse := dotEdge{from: r.City, fromDir: b.Direction}
+					if b.Direction == back && b.City == name && !b.OneWay && !drawn[reverse.key()] {
+						drawn[reverse.key()] = true
+						e.toDir = back
+						break
+					}
+				}
+			}
+
+			edges = append(edges, e)
+		}
+	}
+	return edges
+}
+
+// dotQuote returns s as a quoted DOT string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// dotLabel returns the lines as a quoted DOT label, each line is centered
+func dotLabel(lines []string) string {
+	quoted := make([]string, len(lines))
+	for i, line := range lines {
+		quoted[i] = strings.TrimSuffix(strings.TrimPrefix(dotQuote(line), `"`), `"`)
+	}
+	return `"` + strings.Join(quoted, `\n`) + `"`
+}
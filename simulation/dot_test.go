@@ -0,0 +1,86 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulation_WriteDOT(t *testing.T) {
+	input := `@name Small world
+Foo north=Bar west->Qux
+Bar south=Foo east=Baz
+Baz west=Bar
+Qux
+Zox north=Zox south=Zox`
+
+	sim, err := NewSimulation(strings.NewReader(input), 0, WithMaxMoves(1))
+	require.NoError(t, err)
+
+	// aliens 1 and 2 destroy Baz, alien 3 is trapped in Qux. The aliens have
+	// used up their moves so they don't move on their own
+	sim.aliens = []*alien{
+		{name: 1, currentCity: sim.cities["Baz"], visited: []string{"Baz"}, moves: 1},
+		{name: 2, currentCity: sim.cities["Bar"], visited: []string{"Bar"}, moves: 1},
+		{name: 3, currentCity: sim.cities["Qux"], visited: []string{"Qux"}, moves: 1},
+	}
+	for _, a := range sim.aliens {
+		a.currentCity.visitingAliens = append(a.currentCity.visitingAliens, a)
+	}
+
+	initial := &strings.Builder{}
+	require.NoError(t, sim.WriteDOT(initial, DOTInitial))
+	assert.Equal(t, `digraph world {
+  label="Small world: initial map";
+  labelloc=t;
+  node [shape=box, style=rounded];
+  "Bar" [label="Bar\nalien 2"];
+  "Baz" [label="Baz\nalien 1"];
+  "Foo" [label="Foo"];
+  "Qux" [label="Qux\nalien 3"];
+  "Zox" [label="Zox"];
+  "Bar" -> "Baz" [taillabel="east", headlabel="west", dir=both];
+  "Bar" -> "Foo" [taillabel="south", headlabel="north", dir=both];
+  "Foo" -> "Qux" [taillabel="west"];
+  "Zox" -> "Zox" [taillabel="north", headlabel="south", dir=both];
+}
+`, initial.String())
+
+	sim.aliens[1].goToCity(sim.cities["Baz"])
+	_, err = sim.Step()
+	require.NoError(t, err)
+
+	final := &strings.Builder{}
+	require.NoError(t, sim.WriteDOT(final, DOTFinal))
+	assert.Equal(t, `digraph world {
+  label="Small world: final map after 1 iterations";
+  labelloc=t;
+  node [shape=box, style=rounded];
+  "Bar" [label="Bar"];
+  "Baz" [label="Baz\ndestroyed by alien 1 and alien 2\nin iteration 1", style="rounded,dashed,filled", fillcolor=lightgray, fontcolor=gray40];
+  "Foo" [label="Foo"];
+  "Qux" [label="Qux\nalien 3 (trapped)", color=orange, penwidth=2];
+  "Zox" [label="Zox"];
+  "Bar" -> "Foo" [taillabel="south", headlabel="north", dir=both];
+  "Foo" -> "Qux" [taillabel="west"];
+  "Zox" -> "Zox" [taillabel="north", headlabel="south", dir=both];
+}
+`, final.String())
+
+	overlay := &strings.Builder{}
+	require.NoError(t, sim.WriteDOT(overlay, DOTOverlay))
+	assert.Contains(t, overlay.String(), `"Bar" -> "Baz" [taillabel="east", headlabel="west", dir=both, style=dashed, color=gray];`)
+	assert.Contains(t, overlay.String(), `"Bar" -> "Foo" [taillabel="south", headlabel="north", dir=both];`)
+	assert.Contains(t, overlay.String(), `"Baz" [label="Baz\ndestroyed by alien 1 and alien 2\nin iteration 1"`)
+
+	assert.EqualError(t, sim.WriteDOT(&strings.Builder{}, "sideways"), "unknown DOT view: sideways")
+}
+
+func TestDotQuote(t *testing.T) {
+	assert.Equal(t, `"Foo"`, dotQuote("Foo"))
+	assert.Equal(t, `"Say \"hi\""`, dotQuote(`Say "hi"`))
+	assert.Equal(t, `"a\\b"`, dotQuote(`a\b`))
+	assert.Equal(t, `"New York\nalien 1"`, dotLabel([]string{"New York", "alien 1"}))
+}
@@ -0,0 +1,156 @@
+package simulation
+
+import "fmt"
+
+// ParseErrorKind is the kind of problem found in a map
+type ParseErrorKind int
+
+// parse error kinds
+const (
+	// a city is declared more than once
+	DuplicateCity ParseErrorKind = iota + 1
+	// a road leads to a city that is not declared
+	UnknownNeighbor
+	// a road has a direction that is not valid
+	InvalidDirection
+	// a road has no road back in the opposite direction
+	AsymmetricRoad
+	// a road is not in the format direction=city
+	MalformedRoad
+	// a city has roads in the same direction to different cities
+	ConflictingRoads
+	// a city has the same road more than once, reported as a warning
+	DuplicateRoad
+	// a road leads back to the city itself, reported as a warning
+	SelfRoad
+	// a city name is quoted incorrectly
+	MalformedName
+	// a directive has a missing or invalid value
+	InvalidDirective
+	// a directive is not known, reported as a warning
+	UnknownDirective
+	// a metadata directive is not in the header of the main map, reported as
+	// a warning
+	MisplacedMetadata
+	// an included map cannot be read
+	IncludeFailed
+	// a map includes itself, directly or indirectly
+	IncludeCycle
+	// a JSON map is not valid JSON or doesn't match the schema
+	InvalidJSONMap
+	// an alien placement refers to an unknown city or an invalid alien
+	InvalidPlacement
+	// a line of a placement file is not in the format "alien <n> <city>"
+	MalformedPlacement
+)
+
+var parseErrorKindNames = map[ParseErrorKind]string{
+	DuplicateCity:      "duplicate city",
+	UnknownNeighbor:    "unknown neighbor",
+	InvalidDirection:   "invalid direction",
+	AsymmetricRoad:     "asymmetric road",
+	MalformedRoad:      "malformed road",
+	ConflictingRoads:   "conflicting roads",
+	DuplicateRoad:      "duplicate road",
+	SelfRoad:           "self road",
+	MalformedName:      "malformed name",
+	InvalidDirective:   "invalid directive",
+	UnknownDirective:   "unknown directive",
+	MisplacedMetadata:  "misplaced metadata",
+	IncludeFailed:      "include failed",
+	IncludeCycle:       "include cycle",
+	InvalidJSONMap:     "invalid JSON map",
+	InvalidPlacement:   "invalid placement",
+	MalformedPlacement: "malformed placement",
+}
+
+func (k ParseErrorKind) String() string {
+	if name, ok := parseErrorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ParseErrorKind(%d)", int(k))
+}
+
+// ParseError is a problem found in a map, use errors.As to get it from the
+// errors returned by NewSimulation. Line and Column are 1-based and point to
+// Token, the column is counted in bytes
+type ParseError struct {
+	Kind ParseErrorKind
+	// File is the map or placement file the problem was found in, it is empty
+	// for the main map if no source was set with WithSource
+	File   string
+	Line   int
+	Column int
+	// Token is the offending part of the line, e.g. the city name for
+	// DuplicateCity or the whole road for MalformedRoad
+	Token string
+	// City is the city the problem was found in
+	City string
+	// Direction and Neighbor describe the offending road, they are empty if
+	// they couldn't be parsed
+	Direction string
+	Neighbor  string
+	// ExistingNeighbor is the city the road declared first in Direction leads
+	// to for ConflictingRoads
+	ExistingNeighbor string
+	// ReverseDirection is the direction of the missing road back from
+	// Neighbor to City for AsymmetricRoad
+	ReverseDirection string
+	// Directive is the offending directive without the leading @
+	Directive string
+	// Alien is the offending alien for InvalidPlacement
+	Alien int
+	// Err is the underlying error, e.g. the error opening an included map
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	switch e.Kind {
+	case DuplicateCity:
+		return fmt.Sprintf("duplicate city name: '%s' at line %d", e.City, e.Line)
+	case UnknownNeighbor:
+		return fmt.Sprintf("unknown neighbor city '%s' in direction '%s' for city '%s'", e.Neighbor, e.Direction, e.City)
+	case InvalidDirection:
+		return fmt.Sprintf("invalid direction '%s' for city '%s'", e.Direction, e.City)
+	case AsymmetricRoad:
+		return fmt.Sprintf("neighbor city '%s' has no road in direction '%s' to city '%s'", e.Neighbor, e.ReverseDirection, e.City)
+	case MalformedRoad:
+		return fmt.Sprintf("invalid direction '%s' for city '%s'", e.Token, e.City)
+	case ConflictingRoads:
+		return fmt.Sprintf("conflicting roads in direction '%s' for city '%s' to '%s' and '%s'", e.Direction, e.City, e.ExistingNeighbor, e.Neighbor)
+	case DuplicateRoad:
+		return fmt.Sprintf("duplicate road in direction '%s' for city '%s'", e.Direction, e.City)
+	case SelfRoad:
+		return fmt.Sprintf("road in direction '%s' for city '%s' leads back to the city itself", e.Direction, e.City)
+	case MalformedName:
+		return fmt.Sprintf("malformed city name '%s'", e.Token)
+	case InvalidDirective:
+		if e.Err != nil {
+			return fmt.Sprintf("invalid value '%s' for directive '@%s': %v", e.Token, e.Directive, e.Err)
+		}
+		return fmt.Sprintf("invalid value '%s' for directive '@%s'", e.Token, e.Directive)
+	case UnknownDirective:
+		return fmt.Sprintf("unknown directive '@%s'", e.Directive)
+	case MisplacedMetadata:
+		return fmt.Sprintf("directive '@%s' is only allowed in the header of the main map, it is ignored", e.Directive)
+	case IncludeFailed:
+		return fmt.Sprintf("failed to include '%s': %v", e.Token, e.Err)
+	case IncludeCycle:
+		return fmt.Sprintf("include cycle: %v", e.Err)
+	case InvalidJSONMap:
+		return fmt.Sprintf("invalid JSON map: %v", e.Err)
+	case InvalidPlacement:
+		return fmt.Sprintf("invalid placement of alien %d in city '%s': %v", e.Alien, e.City, e.Err)
+	case MalformedPlacement:
+		if e.Err != nil {
+			return fmt.Sprintf("malformed placement '%s': %v", e.Token, e.Err)
+		}
+		return fmt.Sprintf("malformed placement '%s', expected 'alien <n> <city>'", e.Token)
+	}
+	return fmt.Sprintf("%s at line %d column %d", e.Kind, e.Line, e.Column)
+}
+
+// Unwrap returns the underlying error, if any
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,77 @@
+package simulation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError_errorsAs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected ParseError
+	}{
+		{
+			name:  "duplicate city",
+			input: "A\nB\n  A",
+			expected: ParseError{
+				Kind: DuplicateCity, Line: 3, Column: 3, Token: "A", City: "A",
+			},
+		},
+		{
+			name:  "unknown neighbor",
+			input: "A north=B",
+			expected: ParseError{
+				Kind: UnknownNeighbor, Line: 1, Column: 9, Token: "B", City: "A", Direction: "north", Neighbor: "B",
+			},
+		},
+		{
+			name:  "invalid direction",
+			input: "A up=B\nB",
+			expected: ParseError{
+				Kind: InvalidDirection, Line: 1, Column: 3, Token: "up", City: "A", Direction: "up", Neighbor: "B",
+			},
+		},
+		{
+			name:  "asymmetric road",
+			input: "A north=B\nB",
+			expected: ParseError{
+				Kind: AsymmetricRoad, Line: 1, Column: 3, Token: "north=B", City: "A", Direction: "north", Neighbor: "B", ReverseDirection: "south",
+			},
+		},
+		{
+			name:  "malformed road",
+			input: "A north",
+			expected: ParseError{
+				Kind: MalformedRoad, Line: 1, Column: 3, Token: "north", City: "A",
+			},
+		},
+		{
+			name:  "conflicting roads",
+			input: "A north=B north=C\nB south=A\nC south=A",
+			expected: ParseError{
+				Kind: ConflictingRoads, Line: 1, Column: 11, Token: "north=C", City: "A", Direction: "north", Neighbor: "C", ExistingNeighbor: "B",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewSimulation(strings.NewReader(tc.input), 2)
+
+			var parseErr *ParseError
+			if assert.True(t, errors.As(err, &parseErr), "expected a ParseError, got %v", err) {
+				assert.Equal(t, tc.expected, *parseErr)
+			}
+		})
+	}
+}
+
+func TestParseErrorKind_String(t *testing.T) {
+	assert.Equal(t, "duplicate city", DuplicateCity.String())
+	assert.Equal(t, "asymmetric road", AsymmetricRoad.String())
+	assert.Equal(t, "ParseErrorKind(0)", ParseErrorKind(0).String())
+}
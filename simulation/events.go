@@ -0,0 +1,103 @@
+package simulation
+
+// Event is emitted by the simulation to its observers. It is one of
+// AlienMoved, AliensCrossed, BattleFought, CityDestroyed, AlienTrapped,
+// IterationCompleted or SimulationEnded
+type Event interface {
+	isEvent()
+}
+
+// AlienMoved is emitted when an alien moves from one city to another
+type AlienMoved struct {
+	Iteration int
+	Alien     int
+	From      string
+	To        string
+}
+
+// AliensCrossed is emitted for every crossing on a road with simultaneous
+// moves, before the aliens that complete their move are moved. Aliens
+// contains all crossing aliens, Survivors the aliens alive after the crossing
+type AliensCrossed struct {
+	Iteration int
+	Cities    [2]string
+	Aliens    []int
+	Survivors []int
+	Outcome   CrossingOutcome
+}
+
+// BattleFought is emitted for every battle, before CityDestroyed if the city
+// is destroyed in the battle. Aliens contains all aliens in the battle,
+// Survivors the aliens that survived it
+type BattleFought struct {
+	Iteration     int
+	City          string
+	Aliens        []int
+	Survivors     []int
+	CityDestroyed bool
+}
+
+// CityDestroyed is emitted when a city is destroyed in a battle, Aliens
+// contains the aliens that were killed in the battle
+type CityDestroyed struct {
+	Iteration int
+	City      string
+	Aliens    []int
+}
+
+// AlienTrapped is emitted once when an alien becomes trapped in a city that
+// has no roads left
+type AlienTrapped struct {
+	Iteration int
+	Alien     int
+	City      string
+}
+
+// IterationCompleted is emitted at the end of each iteration with the state
+// of the simulation after the iteration
+type IterationCompleted struct {
+	Iteration int
+	State     SimState
+}
+
+// SimulationEnded is emitted when the simulation reaches an end state or is
+// interrupted
+type SimulationEnded struct {
+	Iterations int
+	State      SimState
+}
+
+func (AlienMoved) isEvent()         {}
+func (AliensCrossed) isEvent()      {}
+func (BattleFought) isEvent()       {}
+func (CityDestroyed) isEvent()      {}
+func (AlienTrapped) isEvent()       {}
+func (IterationCompleted) isEvent() {}
+func (SimulationEnded) isEvent()    {}
+
+// Observer receives the events emitted by a simulation. Events are delivered
+// synchronously in the order they happen, so observers should return quickly
+type Observer interface {
+	OnEvent(e Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as
+// observers
+type ObserverFunc func(e Event)
+
+// OnEvent calls f(e)
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+// Subscribe registers an observer which receives all events emitted by the
+// simulation from now on
+func (s *Simulation) Subscribe(o Observer) {
+	s.observers = append(s.observers, o)
+}
+
+func (s *Simulation) emit(e Event) {
+	for _, o := range s.observers {
+		o.OnEvent(e)
+	}
+}
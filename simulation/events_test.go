@@ -0,0 +1,60 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulation_Subscribe(t *testing.T) {
+	sim := newSettledSimulation(t, "City1 north=City2\nCity2 south=City1",
+		[]string{"City1"},
+		[]string{"City2"},
+		[]string{"City2"},
+	)
+
+	var events []Event
+	sim.Subscribe(ObserverFunc(func(e Event) {
+		events = append(events, e)
+	}))
+
+	_, err := sim.Run()
+	assert.NoError(t, err, "expected no error when running simulation")
+	assert.Equal(t, []Event{
+		BattleFought{Iteration: 1, City: "City2", Aliens: []int{2, 3}, CityDestroyed: true},
+		CityDestroyed{Iteration: 1, City: "City2", Aliens: []int{2, 3}},
+		AlienTrapped{Iteration: 1, Alien: 1, City: "City1"},
+		IterationCompleted{Iteration: 1, State: SimStateAllAliensDeadOrTrapped},
+		SimulationEnded{Iterations: 1, State: SimStateAllAliensDeadOrTrapped},
+	}, events)
+}
+
+func TestSimulation_Subscribe_alienMoved(t *testing.T) {
+	input := `Foo north=Bar east=Baz
+Bar south=Foo
+Baz west=Foo north=Qux
+Qux south=Baz`
+
+	sim, err := NewSimulation(strings.NewReader(input), 3, WithSeed(1))
+	assert.NoError(t, err, "expected no error when creating simulation")
+
+	var moved []AlienMoved
+	sim.Subscribe(ObserverFunc(func(e Event) {
+		if e, ok := e.(AlienMoved); ok {
+			moved = append(moved, e)
+		}
+	}))
+
+	for i := 0; i < 5; i++ {
+		moved = nil
+
+		result, err := sim.Step()
+		assert.NoError(t, err, "expected no error when stepping simulation")
+		assert.Len(t, moved, len(result.Moves), "expected an event for each move")
+
+		for j, move := range result.Moves {
+			assert.Equal(t, AlienMoved{Iteration: result.Iteration, Alien: move.Alien, From: move.From, To: move.To}, moved[j])
+		}
+	}
+}
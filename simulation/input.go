@@ -2,97 +2,590 @@ package simulation
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"unicode"
 
-	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
-func parseInput(input io.Reader) (map[string]*city, error) {
-	intermediateCities := make(map[string][]string)
-	cities := make(map[string]*city)
+// Severity is the severity of a diagnostic
+type Severity string
 
-	scanner := bufio.NewScanner(input)
-	lineNr := 0
-	for scanner.Scan() {
-		lineNr++
+// diagnostic severities
+const (
+	// the map cannot be used
+	SeverityError Severity = "error"
+	// the map can be used but probably doesn't do what was intended
+	SeverityWarning Severity = "warning"
+)
 
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
+// Diagnostic is a problem found in a map. Line and Column are 1-based, the
+// column is counted in bytes
+type Diagnostic struct {
+	// File is the map file the problem was found in, it is empty for the main
+	// map if no source was set with WithSource
+	File     string
+	Line     int
+	Column   int
+	Severity Severity
+	Message  string
+	// Err describes the problem in detail
+	Err *ParseError
+}
+
+// String returns the diagnostic in the format "line:column: severity: message",
+// prefixed with "file:" if the file is known
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s%d:%d: %s: %s", filePrefix(d.File), d.Line, d.Column, d.Severity, d.Message)
+}
+
+// filePrefix returns "file:" to prefix positions with, or an empty string if
+// file is empty
+func filePrefix(file string) string {
+	if file == "" {
+		return ""
+	}
+	return file + ":"
+}
+
+// diagnosticList collects the problems found in a map
+type diagnosticList []Diagnostic
+
+func (l *diagnosticList) report(severity Severity, err *ParseError) {
+	*l = append(*l, Diagnostic{
+		File:     err.File,
+		Line:     err.Line,
+		Column:   err.Column,
+		Severity: severity,
+		Message:  err.Error(),
+		Err:      err,
+	})
+}
+
+// ValidateMap parses the map from input and returns all problems found in it,
+// ordered by their position. Only the options affecting how the map is parsed
+// are used, e.g. WithVocabulary. The returned error is only set if the input
+// cannot be read or the options are invalid
+func ValidateMap(input io.Reader, opts ...Option) ([]Diagnostic, error) {
+	m, err := parseMap(input, parseOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	return m.diagnostics, nil
+}
+
+// parseConfig configures how a map is parsed
+type parseConfig struct {
+	// source is the name of the map file, it is used in diagnostics and
+	// includes are resolved relative to it
+	source string
+	// vocabulary is the name of the vocabulary of directions roads can have,
+	// the default vocabulary is used when empty
+	vocabulary string
+	// repair makes asymmetric roads symmetric instead of reporting them
+	repair bool
+	// placementFile is the path of a placement file replacing the
+	// placements of the map, see WithPlacementFile
+	placementFile string
+}
+
+// parsedMap is the result of parsing a map
+type parsedMap struct {
+	cities      map[string]*city
+	diagnostics []Diagnostic
+	// repairs are the changes made to the map when repairing it
+	repairs    []RoadRepair
+	metadata   Metadata
+	placements []Placement
+}
+
+// token is a whitespace separated part of a line
+type token struct {
+	text   string
+	column int
+}
+
+// cityDecl is a city declared in a map with its roads, independent of the
+// format of the map
+type cityDecl struct {
+	// pos is the position of the city name
+	pos        roadPosition
+	name       string
+	roads      []roadDecl
+	attributes map[string]any
+}
+
+// roadDecl is a road declared in a map
+type roadDecl struct {
+	pos roadPosition
+	// malformed is set if the road couldn't be split into a direction and a
+	// neighbor, the problem is already reported
+	malformed bool
+	dir       direction
+	oneWay    bool
+	// neighbor is the name of the neighbor city, neighborToken and
+	// neighborColumn are the neighbor as written and its position. neighbor
+	// is empty if the name is malformed, the problem is already reported
+	neighbor       string
+	neighborToken  string
+	neighborColumn int
+}
+
+// placementDecl is an alien placement declared in a map
+type placementDecl struct {
+	pos roadPosition
+	Placement
+}
+
+// roadPosition is the position of a road in the map
+type roadPosition struct {
+	file   string
+	line   int
+	column int
+	token  string
+}
+
+// brokenRoad is a road that could not be parsed. Missing reverse roads are
+// not reported for roads which might have a broken road as reverse road, as
+// that is already reported
+type brokenRoad struct {
+	// dir and neighbor are empty if they couldn't be parsed
+	dir      direction
+	neighbor string
+}
+
+// mightBeReverseOf returns true if the broken road of city c might be the
+// reverse road of the road in direction d from city src
+func (r brokenRoad) mightBeReverseOf(src *city, d direction) bool {
+	if src.vocab.isValid(r.dir) {
+		return r.dir == src.opposite(d)
+	}
+	return r.neighbor == "" || r.neighbor == src.name
+}
+
+// parseInput parses the map from input, the first error found in the map is
+// returned
+func parseInput(input io.Reader, cfg parseConfig) (*parsedMap, error) {
+	m, err := parseMap(input, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range m.diagnostics {
+		if d.Severity == SeverityError {
+			return nil, d.Err
 		}
+	}
+
+	return m, nil
+}
 
-		fields := strings.Fields(line)
-		// each line should have at least one field, the city name. Cities without any neighbors are valid as input
-		if len(fields) < 1 {
-			return nil, fmt.Errorf("invalid input format: '%s' at line %d", line, lineNr)
+// parseMap parses the map from input and collects all problems found in it.
+// The cities are only usable if none of the diagnostics is an error. Maps in
+// the JSON format are detected by the extension of the source or by their
+// content, see isJSONMap
+func parseMap(input io.Reader, cfg parseConfig) (*parsedMap, error) {
+	var diagnostics diagnosticList
+	report := diagnostics.report
+
+	var (
+		decls      []cityDecl
+		placements []placementDecl
+		metadata   Metadata
+		// files contains the names of the files read in order
+		files []string
+	)
+	br := bufio.NewReader(input)
+	if isJSONMap(cfg.source, br) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		decls, placements, metadata = readJSONMap(data, cfg.source, &diagnostics)
+		files = []string{cfg.source}
+	} else {
+		r := &mapReader{diagnostics: &diagnostics}
+		if err := r.read(br, cfg.source); err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
 		}
+		decls = lineDecls(r.lines, report)
+		metadata = r.metadata
+		files = r.files
+	}
 
-		cityName := fields[0]
-		if _, exists := intermediateCities[cityName]; exists {
-			return nil, fmt.Errorf("duplicate city name: '%s' at line %d", cityName, lineNr)
+	// a placement file replaces the placements of the map
+	if cfg.placementFile != "" {
+		filePlacements, err := readPlacementFile(cfg.placementFile, report)
+		if err != nil {
+			return nil, err
 		}
-		intermediateCities[cityName] = fields[1:]
-		cities[cityName] = &city{name: cityName, neighbors: make(map[direction]*city)}
+		placements = filePlacements
+		files = append(files, cfg.placementFile)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read input: %w", err)
+	// the vocabulary set by option takes precedence over the one in the
+	// header of the map
+	vocabName := cfg.vocabulary
+	if vocabName == "" {
+		vocabName = metadata.Directions
 	}
+	vocab, err := lookupVocabulary(vocabName)
+	if err != nil {
+		return nil, err
+	}
+
+	cityPositions := make(map[string]roadPosition)
+	cityDecls := make([]cityDecl, 0, len(decls))
+	cities := make(map[string]*city)
+
+	for _, cd := range decls {
+		if first, exists := cityPositions[cd.name]; exists {
+			report(SeverityError, &ParseError{
+				Kind:   DuplicateCity,
+				File:   cd.pos.file,
+				Line:   cd.pos.line,
+				Column: cd.pos.column,
+				Token:  cd.pos.token,
+				City:   cd.name,
+			})
+			// the position is already part of the diagnostic, refer to the
+			// first declaration instead
+			firstDeclared := fmt.Sprintf("line %d", first.line)
+			if first.file != cd.pos.file {
+				firstDeclared = fmt.Sprintf("%s:%d", first.file, first.line)
+			}
+			diagnostics[len(diagnostics)-1].Message = fmt.Sprintf(
+				"duplicate city name: '%s', first declared at %s",
+				cd.name,
+				firstDeclared,
+			)
+			continue
+		}
+		cityPositions[cd.name] = cd.pos
+
+		cityDecls = append(cityDecls, cd)
+		cities[cd.name] = &city{name: cd.name, neighbors: make(map[direction]*city), vocab: vocab, attributes: cd.attributes}
+	}
+
+	positions := make(map[*city]map[direction]roadPosition, len(cities))
+	broken := make(map[*city][]brokenRoad)
+	for _, cd := range cityDecls {
+		c := cities[cd.name]
+		positions[c] = make(map[direction]roadPosition, len(cd.roads))
+
+		for _, road := range cd.roads {
+			if road.malformed {
+				broken[c] = append(broken[c], brokenRoad{})
+				continue
+			}
+
+			dir := road.dir
+			valid := road.neighbor != ""
+			if !vocab.isValid(dir) {
+				report(SeverityError, &ParseError{
+					Kind:      InvalidDirection,
+					File:      road.pos.file,
+					Line:      road.pos.line,
+					Column:    road.pos.column,
+					Token:     string(dir),
+					City:      c.name,
+					Direction: string(dir),
+					Neighbor:  road.neighborToken,
+				})
+				valid = false
+			}
 
-	for cityName, directions := range intermediateCities {
-		for _, neighborField := range directions {
-			neighborFields := strings.SplitN(neighborField, "=", 2)
-			if len(neighborFields) != 2 {
-				return nil, fmt.Errorf(
-					"invalid direction '%s' for city '%s'",
-					neighborField,
-					cityName,
-				)
+			neighbor, exists := cities[road.neighbor]
+			if road.neighbor != "" && !exists {
+				report(SeverityError, &ParseError{
+					Kind:      UnknownNeighbor,
+					File:      road.pos.file,
+					Line:      road.pos.line,
+					Column:    road.neighborColumn,
+					Token:     road.neighborToken,
+					City:      c.name,
+					Direction: string(dir),
+					Neighbor:  road.neighbor,
+				})
+				valid = false
 			}
 
-			neighborName := neighborFields[1]
-			if _, exists := cities[neighborName]; !exists {
-				return nil, fmt.Errorf(
-					"unknown neighbor city '%s' in direction '%s' for city '%s'",
-					neighborName,
-					neighborFields[0],
-					cityName,
-				)
+			if !valid {
+				broken[c] = append(broken[c], brokenRoad{dir: dir, neighbor: road.neighbor})
+				continue
 			}
 
-			dir := direction(neighborFields[0])
-			if !dir.isValid() {
-				return nil, fmt.Errorf(
-					"invalid direction '%s' for city '%s'",
-					neighborFields[0],
-					cityName,
-				)
+			roadErr := &ParseError{
+				File:      road.pos.file,
+				Line:      road.pos.line,
+				Column:    road.pos.column,
+				Token:     road.pos.token,
+				City:      c.name,
+				Direction: string(dir),
+				Neighbor:  neighbor.name,
 			}
-			cities[cityName].neighbors[dir] = cities[neighborName]
+
+			if existing, ok := c.neighbors[dir]; ok {
+				if existing == neighbor {
+					roadErr.Kind = DuplicateRoad
+					report(SeverityWarning, roadErr)
+				} else {
+					roadErr.Kind = ConflictingRoads
+					roadErr.ExistingNeighbor = existing.name
+					report(SeverityError, roadErr)
+				}
+				continue
+			}
+
+			if neighbor == c {
+				roadErr.Kind = SelfRoad
+				roadErr.Column = road.neighborColumn
+				roadErr.Token = road.neighborToken
+				report(SeverityWarning, roadErr)
+			}
+
+			c.neighbors[dir] = neighbor
+			if road.oneWay {
+				if c.oneWay == nil {
+					c.oneWay = make(map[direction]bool)
+				}
+				c.oneWay[dir] = true
+				neighbor.inbound = append(neighbor.inbound, c)
+			}
+			positions[c][dir] = road.pos
 		}
 	}
 
-	// validate cities and neighbors
-	for _, c := range cities {
-
-		directions := maps.Keys(c.neighbors)
-		slices.Sort(directions)
+	// validate cities and neighbors, in input order so repairs are
+	// deterministic. One-way roads don't need a reverse road
+	var repairs []RoadRepair
+	for _, cd := range cityDecls {
+		c := cities[cd.name]
+		for _, d := range c.directions() {
+			if c.oneWay[d] {
+				continue
+			}
 
-		for _, d := range directions {
 			n := c.neighbors[d]
-			neighbor, ok := n.neighbors[d.opposite()]
+			neighbor, ok := n.neighbors[vocab.opposite(d)]
+			if !ok && slices.ContainsFunc(broken[n], func(r brokenRoad) bool { return r.mightBeReverseOf(c, d) }) {
+				continue
+			}
+
 			if !ok || neighbor != c {
-				return nil, fmt.Errorf(
-					"neighbor city '%s' has no road in direction '%s' to city '%s'",
-					n.name,
-					d.opposite(),
-					c.name,
-				)
+				pos := positions[c][d]
+				if cfg.repair {
+					repairs = append(repairs, repairRoad(c, d, pos))
+					continue
+				}
+
+				report(SeverityError, &ParseError{
+					Kind:             AsymmetricRoad,
+					File:             pos.file,
+					Line:             pos.line,
+					Column:           pos.column,
+					Token:            pos.token,
+					City:             c.name,
+					Direction:        string(d),
+					Neighbor:         n.name,
+					ReverseDirection: string(vocab.opposite(d)),
+				})
+			}
+		}
+	}
+
+	placed := checkPlacements(placements, cities, report)
+
+	// diagnostics are ordered by file in the order the files were read
+	fileOrder := make(map[string]int, len(files))
+	for i, file := range files {
+		fileOrder[file] = i
+	}
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) bool {
+		if a.File != b.File {
+			return fileOrder[a.File] < fileOrder[b.File]
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return &parsedMap{
+		cities:      cities,
+		diagnostics: diagnostics,
+		repairs:     repairs,
+		metadata:    metadata,
+		placements:  placed,
+	}, nil
+}
+
+// lineDecls returns the cities declared by the lines of a map in the line
+// format, malformed names and roads are reported
+func lineDecls(lines []mapLine, report func(Severity, *ParseError)) []cityDecl {
+	decls := make([]cityDecl, 0, len(lines))
+	for _, ml := range lines {
+		// each city line has at least one field, the city name. Cities
+		// without any neighbors are valid as input
+		nameToken := ml.tokens[0]
+		cityName, ok := parseName(nameToken.text)
+		if !ok {
+			report(SeverityError, &ParseError{
+				Kind:   MalformedName,
+				File:   ml.file,
+				Line:   ml.line,
+				Column: nameToken.column,
+				Token:  nameToken.text,
+			})
+			continue
+		}
+
+		cd := cityDecl{
+			pos:   roadPosition{file: ml.file, line: ml.line, column: nameToken.column, token: nameToken.text},
+			name:  cityName,
+			roads: make([]roadDecl, 0, len(ml.tokens)-1),
+		}
+		for _, road := range ml.tokens[1:] {
+			pos := roadPosition{file: ml.file, line: ml.line, column: road.column, token: road.text}
+
+			fields, separator, ok := splitRoad(road.text)
+			if !ok {
+				report(SeverityError, &ParseError{
+					Kind:   MalformedRoad,
+					File:   ml.file,
+					Line:   ml.line,
+					Column: road.column,
+					Token:  road.text,
+					City:   cityName,
+				})
+				cd.roads = append(cd.roads, roadDecl{pos: pos, malformed: true})
+				continue
+			}
+
+			rd := roadDecl{
+				pos:            pos,
+				dir:            direction(fields[0]),
+				oneWay:         separator == oneWaySeparator,
+				neighborToken:  fields[1],
+				neighborColumn: road.column + len(fields[0]) + len(separator),
+			}
+			neighborName, ok := parseName(rd.neighborToken)
+			if !ok {
+				report(SeverityError, &ParseError{
+					Kind:      MalformedName,
+					File:      ml.file,
+					Line:      ml.line,
+					Column:    rd.neighborColumn,
+					Token:     rd.neighborToken,
+					City:      cityName,
+					Direction: fields[0],
+				})
+			}
+			rd.neighbor = neighborName
+			cd.roads = append(cd.roads, rd)
+		}
+		decls = append(decls, cd)
+	}
+	return decls
+}
+
+// checkPlacements returns the placements of aliens in known cities, invalid
+// placements are reported
+func checkPlacements(placements []placementDecl, cities map[string]*city, report func(Severity, *ParseError)) []Placement {
+	var valid []Placement
+	placed := make(map[int]bool, len(placements))
+	for _, p := range placements {
+		var err error
+		switch _, exists := cities[p.City]; {
+		case p.Alien < 1:
+			err = errors.New("aliens are numbered from 1")
+		case placed[p.Alien]:
+			err = errors.New("the alien is already placed")
+		case !exists:
+			err = errors.New("unknown city")
+		}
+		if err != nil {
+			report(SeverityError, &ParseError{
+				Kind:   InvalidPlacement,
+				File:   p.pos.file,
+				Line:   p.pos.line,
+				Column: p.pos.column,
+				Token:  p.pos.token,
+				City:   p.City,
+				Alien:  p.Alien,
+				Err:    err,
+			})
+			continue
+		}
+
+		placed[p.Alien] = true
+		valid = append(valid, p.Placement)
+	}
+	return valid
+}
+
+// road separators, "north=Bar" is a road in both directions and "north->Bar"
+// is a one-way road from the city to Bar
+const (
+	twoWaySeparator = "="
+	oneWaySeparator = "->"
+)
+
+// splitRoad splits a road into its direction and neighbor, and returns the
+// separator between them. ok is false if the road has no separator
+func splitRoad(road string) (fields [2]string, separator string, ok bool) {
+	i := strings.Index(road, twoWaySeparator)
+	separator = twoWaySeparator
+	if j := strings.Index(road, oneWaySeparator); j >= 0 && (i < 0 || j < i) {
+		i = j
+		separator = oneWaySeparator
+	}
+	if i < 0 {
+		return fields, "", false
+	}
+
+	return [2]string{road[:i], road[i+len(separator):]}, separator, true
+}
+
+// tokenize splits a line into whitespace separated tokens and records the
+// 1-based byte column of each token. Whitespace between quotes is part of the
+// token, a quote can be escaped with a backslash. A token starting with # and
+// the rest of the line are a comment
+func tokenize(line string) []token {
+	var tokens []token
+
+	start := -1
+	quoted, escaped := false, false
+	for i, r := range line {
+		switch {
+		case start < 0 && r == comment:
+			// the rest of the line is a comment
+			return tokens
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == quote:
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if start >= 0 {
+				tokens = append(tokens, token{text: line[start:i], column: start + 1})
+				start = -1
 			}
+			continue
+		}
+
+		if start < 0 {
+			start = i
 		}
 	}
-	return cities, nil
+
+	if start >= 0 {
+		tokens = append(tokens, token{text: line[start:], column: start + 1})
+	}
+
+	return tokens
 }
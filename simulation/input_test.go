@@ -121,13 +121,129 @@ func TestParseInput(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actual, err := parseInput(strings.NewReader(tc.input))
+			actual, err := parseInput(strings.NewReader(tc.input), parseConfig{})
 			if tc.expectedError != "" {
 				assert.EqualError(t, err, tc.expectedError)
 			} else {
 				assert.NoError(t, err)
-				assert.JSONEq(t, toJSON(tc.expected), toJSON(actual))
+				assert.JSONEq(t, toJSON(tc.expected), toJSON(actual.cities))
 			}
 		})
 	}
 }
+
+func TestValidateMap(t *testing.T) {
+	input := `Foo north=Bar east=Baz west=Qux
+Bar south=Foo east=Foo
+Baz west=Foo up=Bar
+Foo south=Bar
+Qux east=Foo east=Foo
+Zox north=Zox south=Zox
+Vex south=Vex south=Bar invalid`
+
+	diagnostics, err := ValidateMap(strings.NewReader(input))
+	assert.NoError(t, err, "expected no error when validating map")
+
+	actual := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		actual[i] = d.String()
+	}
+	assert.Equal(t, []string{
+		"2:15: error: neighbor city 'Foo' has no road in direction 'west' to city 'Bar'",
+		"3:14: error: invalid direction 'up' for city 'Baz'",
+		"4:1: error: duplicate city name: 'Foo', first declared at line 1",
+		"5:14: warning: duplicate road in direction 'east' for city 'Qux'",
+		"6:11: warning: road in direction 'north' for city 'Zox' leads back to the city itself",
+		"6:21: warning: road in direction 'south' for city 'Zox' leads back to the city itself",
+		"7:11: warning: road in direction 'south' for city 'Vex' leads back to the city itself",
+		"7:15: error: conflicting roads in direction 'south' for city 'Vex' to 'Vex' and 'Bar'",
+		"7:25: error: invalid direction 'invalid' for city 'Vex'",
+	}, actual)
+
+	_, err = parseInput(strings.NewReader(input), parseConfig{})
+	assert.EqualError(t, err, "neighbor city 'Foo' has no road in direction 'west' to city 'Bar'", "expected parseInput to return the first error")
+}
+
+func TestParseInput_oneWayRoads(t *testing.T) {
+	input := `Foo north->Bar east=Baz
+Bar west->Baz
+Baz west=Foo`
+
+	m, err := parseInput(strings.NewReader(input), parseConfig{})
+	assert.NoError(t, err, "expected one-way roads to not need a reverse road")
+
+	foo, bar, baz := m.cities["Foo"], m.cities["Bar"], m.cities["Baz"]
+	assert.Equal(t, map[direction]bool{north: true}, foo.oneWay)
+	assert.Equal(t, []*city{foo}, bar.inbound)
+	assert.Equal(t, []*city{bar}, baz.inbound)
+	assert.Equal(t, "Bar west->Baz\nBaz west=Foo\nFoo east=Baz north->Bar\n", (&Simulation{}).CitiesToString(m.cities))
+
+	diagnostics, err := ValidateMap(strings.NewReader("Foo north->Bar\nBar south=Foo east->Qux"))
+	assert.NoError(t, err)
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, "2:21: error: unknown neighbor city 'Qux' in direction 'east' for city 'Bar'", diagnostics[0].String())
+	}
+}
+
+func TestSplitRoad(t *testing.T) {
+	testCases := []struct {
+		road      string
+		fields    [2]string
+		separator string
+		ok        bool
+	}{
+		{"north=Bar", [2]string{"north", "Bar"}, "=", true},
+		{"north->Bar", [2]string{"north", "Bar"}, "->", true},
+		{"north=Bar->Baz", [2]string{"north", "Bar->Baz"}, "=", true},
+		{"north->Bar=Baz", [2]string{"north", "Bar=Baz"}, "->", true},
+		{"north", [2]string{}, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.road, func(t *testing.T) {
+			fields, separator, ok := splitRoad(tc.road)
+			assert.Equal(t, tc.fields, fields)
+			assert.Equal(t, tc.separator, separator)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestValidateMap_malformedNames(t *testing.T) {
+	input := `"New York north=Foo
+Foo south="Bar
+"Bar" north=Foo"s`
+
+	diagnostics, err := ValidateMap(strings.NewReader(input))
+	assert.NoError(t, err, "expected no error when validating map")
+
+	actual := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		actual[i] = d.String()
+	}
+	assert.Equal(t, []string{
+		`1:1: error: malformed city name '"New York north=Foo'`,
+		`2:11: error: malformed city name '"Bar'`,
+		`3:13: error: malformed city name 'Foo"s'`,
+	}, actual)
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []token{
+		{text: "Foo", column: 3},
+		{text: "north=Bar", column: 7},
+		{text: "east=Baz", column: 18},
+	}, tokenize("  Foo north=Bar \teast=Baz"))
+	assert.Equal(t, []token{
+		{text: `"New York"`, column: 1},
+		{text: `north="Los \" Angeles"`, column: 12},
+		{text: `east="Unterminated`, column: 35},
+	}, tokenize(`"New York" north="Los \" Angeles" east="Unterminated`))
+	assert.Equal(t, []token{
+		{text: "Foo", column: 1},
+		{text: `north="Bar # Baz"`, column: 5},
+	}, tokenize(`Foo north="Bar # Baz" # a comment`))
+	assert.Equal(t, []token{{text: "Foo#1", column: 1}}, tokenize("Foo#1"))
+	assert.Empty(t, tokenize(" \t "))
+	assert.Empty(t, tokenize("  # a comment"))
+}
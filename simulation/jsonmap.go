@@ -0,0 +1,378 @@
+package simulation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// MapSchemaVersion is the version of the JSON map schema, it is increased
+// whenever a field is renamed, removed or changes meaning
+const MapSchemaVersion = 1
+
+// jsonMapExtension is the extension of map files in the JSON format
+const jsonMapExtension = ".json"
+
+// jsonMap is a map in the JSON format, see WriteMapJSON
+type jsonMap struct {
+	SchemaVersion int         `json:"schema_version"`
+	Name          string      `json:"name,omitempty"`
+	Author        string      `json:"author,omitempty"`
+	Aliens        int         `json:"aliens,omitempty"`
+	Directions    string      `json:"directions,omitempty"`
+	Cities        []jsonCity  `json:"cities"`
+	Placements    []Placement `json:"placements,omitempty"`
+}
+
+// jsonCity is a city in a JSON map
+type jsonCity struct {
+	Name       string         `json:"name"`
+	Roads      []Road         `json:"roads,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// isJSONMap returns true if the map read from input is in the JSON format,
+// which is the case if the name of the source ends in ".json" or the first
+// character other than whitespace is "{"
+func isJSONMap(source string, input *bufio.Reader) bool {
+	if strings.EqualFold(filepath.Ext(source), jsonMapExtension) {
+		return true
+	}
+
+	for n := 1; ; n++ {
+		peeked, err := input.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch b := peeked[n-1]; b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return b == '{'
+		}
+	}
+}
+
+// readJSONMap returns the cities, alien placements and metadata declared by
+// a JSON map, problems are reported to diagnostics
+func readJSONMap(data []byte, file string, diagnostics *diagnosticList) ([]cityDecl, []placementDecl, Metadata) {
+	positions := newJSONPositions(data, file)
+
+	var doc jsonMap
+	if err := json.Unmarshal(data, &doc); err != nil {
+		parseErr := &ParseError{Kind: InvalidJSONMap, Err: err}
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			// the offset is after the offending character
+			positions.set(parseErr, syntaxErr.Offset-1)
+		case errors.As(err, &typeErr):
+			// the offset may be after the offending value, the document is
+			// valid JSON so the start of the value can be found
+			start := int64(0)
+			_ = walkJSON(data, func(_ []string, offset int64) {
+				if offset < typeErr.Offset {
+					start = offset
+				}
+			})
+			positions.set(parseErr, start)
+			field := "the map"
+			if typeErr.Field != "" {
+				field = fmt.Sprintf("'%s'", typeErr.Field)
+			}
+			parseErr.Err = fmt.Errorf("%s must be %s, not %s", field, jsonTypeName(typeErr.Type), typeErr.Value)
+		default:
+			positions.set(parseErr, 0)
+		}
+		diagnostics.report(SeverityError, parseErr)
+		return nil, nil, Metadata{}
+	}
+
+	// the document is valid, so the positions of its values can be found
+	var (
+		fieldOffsets     = make(map[string]int64)
+		cityOffsets      []int64
+		roadOffsets      [][]int64
+		placementOffsets []int64
+	)
+	_ = walkJSON(data, func(path []string, offset int64) {
+		// keys are matched like encoding/json does, the last duplicate key
+		// wins
+		is := func(i int, key string) bool { return strings.EqualFold(path[i], key) }
+		switch {
+		case len(path) == 1:
+			fieldOffsets[strings.ToLower(path[0])] = offset
+			if is(0, "cities") {
+				cityOffsets, roadOffsets = nil, nil
+			} else if is(0, "placements") {
+				placementOffsets = nil
+			}
+		case len(path) == 2 && is(0, "cities"):
+			cityOffsets = append(cityOffsets, offset)
+			roadOffsets = append(roadOffsets, nil)
+		case len(path) == 4 && is(0, "cities") && is(2, "roads"):
+			roadOffsets[len(roadOffsets)-1] = append(roadOffsets[len(roadOffsets)-1], offset)
+		case len(path) == 2 && is(0, "placements"):
+			placementOffsets = append(placementOffsets, offset)
+		}
+	})
+
+	fieldErr := func(field string, err error) {
+		parseErr := &ParseError{Kind: InvalidJSONMap, Err: err}
+		positions.set(parseErr, fieldOffsets[field])
+		diagnostics.report(SeverityError, parseErr)
+	}
+
+	if doc.SchemaVersion > MapSchemaVersion {
+		fieldErr("schema_version", fmt.Errorf("unsupported schema version %d, the latest is %d", doc.SchemaVersion, MapSchemaVersion))
+		return nil, nil, Metadata{}
+	}
+
+	metadata := Metadata{Name: doc.Name, Author: doc.Author}
+	if doc.Aliens < 0 {
+		fieldErr("aliens", fmt.Errorf("'aliens' must not be negative"))
+	} else {
+		metadata.Aliens = doc.Aliens
+	}
+	if doc.Directions != "" {
+		if _, err := lookupVocabulary(doc.Directions); err != nil {
+			fieldErr("directions", err)
+		} else {
+			metadata.Directions = doc.Directions
+		}
+	}
+
+	decls := make([]cityDecl, 0, len(doc.Cities))
+	for i, c := range doc.Cities {
+		pos := positions.at(cityOffsets[i])
+		pos.token = c.Name
+		if c.Name == "" {
+			diagnostics.report(SeverityError, &ParseError{
+				Kind:   MalformedName,
+				File:   pos.file,
+				Line:   pos.line,
+				Column: pos.column,
+			})
+			continue
+		}
+
+		name := normalizeName(c.Name)
+		cd := cityDecl{pos: pos, name: name, roads: make([]roadDecl, 0, len(c.Roads)), attributes: c.Attributes}
+		for j, r := range c.Roads {
+			separator := twoWaySeparator
+			if r.OneWay {
+				separator = oneWaySeparator
+			}
+
+			rd := roadDecl{
+				pos:            positions.at(roadOffsets[i][j]),
+				dir:            direction(r.Direction),
+				oneWay:         r.OneWay,
+				neighborToken:  r.City,
+				neighborColumn: positions.at(roadOffsets[i][j]).column,
+			}
+			rd.pos.token = r.Direction + separator + quoteName(r.City)
+			if r.City == "" {
+				diagnostics.report(SeverityError, &ParseError{
+					Kind:      MalformedName,
+					File:      rd.pos.file,
+					Line:      rd.pos.line,
+					Column:    rd.pos.column,
+					City:      name,
+					Direction: r.Direction,
+				})
+			} else {
+				rd.neighbor = normalizeName(r.City)
+			}
+			cd.roads = append(cd.roads, rd)
+		}
+		decls = append(decls, cd)
+	}
+
+	placements := make([]placementDecl, len(doc.Placements))
+	for i, p := range doc.Placements {
+		placements[i] = placementDecl{
+			pos:       positions.at(placementOffsets[i]),
+			Placement: Placement{Alien: p.Alien, City: normalizeName(p.City)},
+		}
+	}
+
+	return decls, placements, metadata
+}
+
+// jsonPositions converts byte offsets in a JSON document to positions
+type jsonPositions struct {
+	file string
+	// lineStarts contains the offset of the first byte of each line
+	lineStarts []int64
+}
+
+func newJSONPositions(data []byte, file string) *jsonPositions {
+	p := &jsonPositions{file: file, lineStarts: []int64{0}}
+	for i, b := range data {
+		if b == '\n' {
+			p.lineStarts = append(p.lineStarts, int64(i+1))
+		}
+	}
+	return p
+}
+
+// at returns the position of the byte at offset
+func (p *jsonPositions) at(offset int64) roadPosition {
+	if offset < 0 {
+		offset = 0
+	}
+	line := sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset }) - 1
+	return roadPosition{file: p.file, line: line + 1, column: int(offset-p.lineStarts[line]) + 1}
+}
+
+// set sets the position of err to the byte at offset
+func (p *jsonPositions) set(err *ParseError, offset int64) {
+	pos := p.at(offset)
+	err.File, err.Line, err.Column = pos.file, pos.line, pos.column
+}
+
+// walkJSON calls visit with the path and the offset of every value in the
+// JSON document, in document order. The path contains the keys of objects
+// and the indexes of arrays leading to the value, e.g. ["cities", "0"]
+func walkJSON(data []byte, visit func(path []string, offset int64)) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var path []string
+
+	var walk func() error
+	walk = func() error {
+		// the offset is after the previous token, skip to the value
+		offset := decoder.InputOffset()
+		for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+			offset++
+		}
+
+		t, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		visit(path, offset)
+
+		delim, ok := t.(json.Delim)
+		if !ok {
+			return nil
+		}
+		for i := 0; decoder.More(); i++ {
+			key := strconv.Itoa(i)
+			if delim == '{' {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key, _ = keyToken.(string)
+			}
+
+			path = append(path, key)
+			err := walk()
+			path = path[:len(path)-1]
+			if err != nil {
+				return err
+			}
+		}
+
+		// the closing delimiter
+		_, err = decoder.Token()
+		return err
+	}
+
+	return walk()
+}
+
+// jsonTypeName returns the name of the JSON type a Go type is decoded from
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
+
+// WriteMapJSON writes the cities and their roads to w as a JSON map, along
+// with the metadata of the map and the cities the alive aliens are in. It can
+// be read back by NewSimulation with MapAliens, e.g. to continue a
+// simulation:
+//
+//	{
+//	  "schema_version": 1,
+//	  "name": "Small world",
+//	  "directions": "compass",
+//	  "cities": [
+//	    {"name": "Bar", "roads": [{"direction": "south", "city": "Foo"}], "attributes": {"population": 1200}},
+//	    {"name": "Foo", "roads": [{"direction": "north", "city": "Bar"}, {"direction": "west", "city": "Baz", "one_way": true}]}
+//	  ],
+//	  "placements": [{"alien": 2, "city": "Foo"}]
+//	}
+//
+// Cities are ordered by name and their roads by direction
+func (s *Simulation) WriteMapJSON(w io.Writer, cities map[string]*city) error {
+	doc := jsonMap{
+		SchemaVersion: MapSchemaVersion,
+		Name:          s.metadata.Name,
+		Author:        s.metadata.Author,
+		Aliens:        s.metadata.Aliens,
+		Directions:    s.metadata.Directions,
+		Cities:        make([]jsonCity, 0, len(cities)),
+	}
+	// the vocabulary set by option takes precedence, the map can't be read
+	// back without it
+	if s.parse.vocabulary != "" {
+		doc.Directions = s.parse.vocabulary
+	}
+
+	cityNames := make([]string, 0, len(cities))
+	for name := range cities {
+		cityNames = append(cityNames, name)
+	}
+	slices.Sort(cityNames)
+
+	for _, name := range cityNames {
+		c := cities[name]
+		jc := jsonCity{Name: c.name, Attributes: c.attributes}
+		if len(c.neighbors) > 0 {
+			jc.Roads = c.roads()
+		}
+		doc.Cities = append(doc.Cities, jc)
+	}
+
+	// the placements describe the aliens of a simulation, the default number
+	// of aliens would add new aliens when the map is read back
+	if len(s.aliens) > 0 {
+		doc.Aliens = 0
+	}
+	for _, a := range s.aliens {
+		if !a.isDead() && cities[a.currentCity.name] == a.currentCity {
+			doc.Placements = append(doc.Placements, Placement{Alien: a.name, City: a.currentCity.name})
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write JSON map: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package simulation
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsJSONMap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		input    string
+		expected bool
+	}{
+		{"json extension", "map.json", "Foo north=Bar", true},
+		{"upper case extension", "MAP.JSON", "", true},
+		{"object", "", `{"cities": []}`, true},
+		{"object after whitespace", "map.txt", " \n\t{}", true},
+		{"line format", "", "Foo north=Bar", false},
+		{"empty", "", "", false},
+		{"only whitespace", "", " \n ", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			br := bufio.NewReader(strings.NewReader(tc.input))
+			assert.Equal(t, tc.expected, isJSONMap(tc.source, br))
+
+			// the input is not consumed
+			rest := &strings.Builder{}
+			_, err := br.WriteTo(rest)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.input, rest.String())
+		})
+	}
+}
+
+func TestParseInput_jsonMap(t *testing.T) {
+	input := `{
+  "schema_version": 1,
+  "name": "Small world",
+  "aliens": 3,
+  "directions": "compass",
+  "cities": [
+    {"name": "Foo", "roads": [{"direction": "north", "city": "Bar"}, {"direction": "east", "city": "New York", "one_way": true}]},
+    {"name": "Bar", "roads": [{"direction": "south", "city": "Foo"}], "attributes": {"population": 1200, "country": "Utopia"}},
+    {"name": "New York"}
+  ],
+  "placements": [{"alien": 2, "city": "New York"}]
+}`
+
+	parsed, err := parseInput(strings.NewReader(input), parseConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, parsed.diagnostics)
+	assert.Equal(t, Metadata{Name: "Small world", Aliens: 3, Directions: "compass"}, parsed.metadata)
+	assert.Equal(t, []Placement{{Alien: 2, City: "New York"}}, parsed.placements)
+
+	require.Len(t, parsed.cities, 3)
+	foo := parsed.cities["Foo"]
+	assert.Equal(t, "Bar", foo.neighbors[north].name)
+	assert.Equal(t, "New York", foo.neighbors[east].name)
+	assert.True(t, foo.oneWay[east], "expected the road to the east to be one-way")
+	assert.Equal(t, map[string]any{"population": 1200.0, "country": "Utopia"}, parsed.cities["Bar"].attributes)
+	assert.Nil(t, foo.attributes)
+}
+
+func TestValidateMap_jsonMap(t *testing.T) {
+	input := `{
+  "cities": [
+    {"name": "Foo", "roads": [
+      {"direction": "north", "city": "Bar"},
+      {"direction": "up", "city": "Bar"},
+      {"direction": "west", "city": "Qux"},
+      {"direction": "east", "city": ""}
+    ]},
+    {"name": "Bar"},
+    {"name": "Foo"},
+    {"name": ""}
+  ],
+  "placements": [{"alien": 1, "city": "Foo"}, {"alien": 1, "city": "Bar"}, {"alien": 2, "city": "Qux"}, {"alien": 0, "city": "Foo"}]
+}`
+
+	diagnostics, err := ValidateMap(strings.NewReader(input), WithSource("map.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"map.json:4:7: error: neighbor city 'Bar' has no road in direction 'south' to city 'Foo'",
+		"map.json:5:7: error: invalid direction 'up' for city 'Foo'",
+		"map.json:6:7: error: unknown neighbor city 'Qux' in direction 'west' for city 'Foo'",
+		"map.json:7:7: error: malformed city name ''",
+		"map.json:10:5: error: duplicate city name: 'Foo', first declared at line 3",
+		"map.json:11:5: error: malformed city name ''",
+		"map.json:13:47: error: invalid placement of alien 1 in city 'Bar': the alien is already placed",
+		"map.json:13:76: error: invalid placement of alien 2 in city 'Qux': unknown city",
+		"map.json:13:105: error: invalid placement of alien 0 in city 'Foo': aliens are numbered from 1",
+	}, diagnosticStrings(diagnostics))
+}
+
+func TestValidateMap_invalidJSONMap(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "syntax error",
+			input:    "{\n  \"cities\": [}\n}",
+			expected: "2:14: error: invalid JSON map: invalid character '}' looking for beginning of value",
+		},
+		{
+			name:     "unexpected end",
+			input:    `{"cities": [`,
+			expected: "1:12: error: invalid JSON map: unexpected end of JSON input",
+		},
+		{
+			name:     "wrong type",
+			input:    "{\n  \"cities\": [{\"name\": 42}]\n}",
+			expected: "2:23: error: invalid JSON map: 'cities.0.name' must be a string, not number",
+		},
+		{
+			name:     "unsupported schema version",
+			input:    `{"schema_version": 2, "cities": []}`,
+			expected: "1:20: error: invalid JSON map: unsupported schema version 2, the latest is 1",
+		},
+		{
+			name:     "negative aliens",
+			input:    `{"aliens": -1, "cities": []}`,
+			expected: "1:12: error: invalid JSON map: 'aliens' must not be negative",
+		},
+		{
+			name:     "unknown directions",
+			input:    `{"directions": "polar", "cities": []}`,
+			expected: "1:16: error: invalid JSON map: unknown vocabulary 'polar'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diagnostics, err := ValidateMap(strings.NewReader(tc.input))
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tc.expected}, diagnosticStrings(diagnostics))
+		})
+	}
+}
+
+func TestSimulation_WriteMapJSON(t *testing.T) {
+	input := `{
+  "name": "Small world",
+  "directions": "compass3d",
+  "cities": [
+    {"name": "Foo", "roads": [{"direction": "up", "city": "Bar"}, {"direction": "east", "city": "New York", "one_way": true}]},
+    {"name": "Bar", "roads": [{"direction": "down", "city": "Foo"}], "attributes": {"population": 1200}},
+    {"name": "New York"}
+  ],
+  "placements": [{"alien": 2, "city": "New York"}]
+}`
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens, WithSeed(1))
+	require.NoError(t, err)
+
+	output := &strings.Builder{}
+	require.NoError(t, sim.WriteMapJSON(output, sim.SurvivedCities()))
+	assert.Equal(t, `{
+  "schema_version": 1,
+  "name": "Small world",
+  "directions": "compass3d",
+  "cities": [
+    {
+      "name": "Bar",
+      "roads": [
+        {
+          "direction": "down",
+          "city": "Foo"
+        }
+      ],
+      "attributes": {
+        "population": 1200
+      }
+    },
+    {
+      "name": "Foo",
+      "roads": [
+        {
+          "direction": "east",
+          "city": "New York",
+          "one_way": true
+        },
+        {
+          "direction": "up",
+          "city": "Bar"
+        }
+      ]
+    },
+    {
+      "name": "New York"
+    }
+  ],
+  "placements": [
+    {
+      "alien": 2,
+      "city": "New York"
+    }
+  ]
+}
+`, output.String())
+
+	// the written map is read back as the same world
+	readBack, err := NewSimulation(strings.NewReader(output.String()), MapAliens, WithSeed(1))
+	require.NoError(t, err)
+	assert.Equal(t, sim.CitiesToString(sim.cities), readBack.CitiesToString(readBack.cities))
+	assert.Equal(t, sim.Result().Aliens, readBack.Result().Aliens)
+}
+
+func TestNewSimulation_placements(t *testing.T) {
+	input := `{
+  "cities": [
+    {"name": "Foo", "roads": [{"direction": "north", "city": "Bar"}]},
+    {"name": "Bar", "roads": [{"direction": "south", "city": "Foo"}]}
+  ],
+  "placements": [{"alien": 3, "city": "Foo"}, {"alien": 1, "city": "Bar"}]
+}`
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens)
+	require.NoError(t, err)
+	if assert.Len(t, sim.aliens, 2, "expected only the placed aliens") {
+		assert.Equal(t, 1, sim.aliens[0].name)
+		assert.Equal(t, "Bar", sim.aliens[0].currentCity.name)
+		assert.Equal(t, 3, sim.aliens[1].name)
+		assert.Equal(t, "Foo", sim.aliens[1].currentCity.name)
+	}
+
+	sim, err = NewSimulation(strings.NewReader(input), 4)
+	require.NoError(t, err)
+	if assert.Len(t, sim.aliens, 4, "expected the unplaced aliens to be placed randomly") {
+		assert.Equal(t, "Bar", sim.aliens[0].currentCity.name)
+		assert.Equal(t, "Foo", sim.aliens[2].currentCity.name)
+		assert.NotNil(t, sim.aliens[1].currentCity)
+		assert.NotNil(t, sim.aliens[3].currentCity)
+	}
+
+	_, err = NewSimulation(strings.NewReader(input), 2)
+	assert.EqualError(t, err, "alien 3 is placed in city 'Foo', but there are only 2 aliens")
+}
@@ -0,0 +1,242 @@
+package simulation
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// special characters of the map format
+const (
+	// comment starts a comment running until the end of the line
+	comment = '#'
+	// directivePrefix starts a directive, e.g. "@include other.map"
+	directivePrefix = "@"
+)
+
+// directives
+const (
+	directiveInclude    = "include"
+	directiveName       = "name"
+	directiveAuthor     = "author"
+	directiveAliens     = "aliens"
+	directiveDirections = "directions"
+)
+
+// Metadata is the information in the header of a map. The header consists of
+// the directives before the first city of the main map, e.g.
+//
+//	@name Europe
+//	@author Jane Doe
+//	@aliens 10
+//	@directions compass8
+type Metadata struct {
+	Name   string
+	Author string
+	// Aliens is the default number of aliens to place in the map, 0 if not
+	// set
+	Aliens int
+	// Directions is the vocabulary of directions of the map, empty if not set
+	Directions string
+}
+
+// mapLine is a line of a map declaring a city
+type mapLine struct {
+	file   string
+	line   int
+	tokens []token
+}
+
+// includedFile is a map file being read
+type includedFile struct {
+	name string
+	// key identifies the file independent of the path used to include it
+	key string
+}
+
+// mapReader reads the lines declaring cities from a map and the maps it
+// includes, and the metadata in the header of the main map
+type mapReader struct {
+	diagnostics *diagnosticList
+	lines       []mapLine
+	metadata    Metadata
+	// files contains the names of all files in the order they were read,
+	// stack contains the files currently being read to detect include
+	// cycles
+	files []string
+	stack []includedFile
+	// inBody is set once the first city of the main map has been read
+	inBody bool
+}
+
+// read reads the map file from input, file is the name used in diagnostics
+// and to resolve includes
+func (r *mapReader) read(input io.Reader, file string) error {
+	main := len(r.stack) == 0
+	r.files = append(r.files, file)
+	r.stack = append(r.stack, includedFile{name: file, key: includeKey(file)})
+	defer func() {
+		r.stack = r.stack[:len(r.stack)-1]
+	}()
+
+	scanner := bufio.NewScanner(input)
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr++
+
+		tokens := tokenize(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(tokens[0].text, directivePrefix) {
+			r.directive(file, lineNr, tokens, main)
+			continue
+		}
+
+		if main {
+			r.inBody = true
+		}
+		r.lines = append(r.lines, mapLine{file: file, line: lineNr, tokens: tokens})
+	}
+
+	return scanner.Err()
+}
+
+// directive handles a directive line, tokens[0] is the directive itself
+func (r *mapReader) directive(file string, line int, tokens []token, main bool) {
+	name := strings.TrimPrefix(tokens[0].text, directivePrefix)
+	err := &ParseError{
+		File:      file,
+		Line:      line,
+		Column:    tokens[0].column,
+		Directive: name,
+	}
+
+	value, ok := directiveValue(tokens[1:])
+	if len(tokens) > 1 {
+		// point to the value instead of the directive
+		err.Column = tokens[1].column
+		err.Token = value
+		if !ok {
+			err.Token = tokens[1].text
+		}
+	}
+
+	switch name {
+	case directiveInclude:
+		if !ok || value == "" {
+			err.Kind = InvalidDirective
+			r.diagnostics.report(SeverityError, err)
+			return
+		}
+		r.include(file, value, err)
+		return
+	case directiveName, directiveAuthor, directiveAliens, directiveDirections:
+	default:
+		err.Kind = UnknownDirective
+		err.Column = tokens[0].column
+		err.Token = tokens[0].text
+		r.diagnostics.report(SeverityWarning, err)
+		return
+	}
+
+	if !main || r.inBody {
+		err.Kind = MisplacedMetadata
+		r.diagnostics.report(SeverityWarning, err)
+		return
+	}
+
+	if !ok || value == "" {
+		err.Kind = InvalidDirective
+		r.diagnostics.report(SeverityError, err)
+		return
+	}
+
+	switch name {
+	case directiveName:
+		r.metadata.Name = value
+	case directiveAuthor:
+		r.metadata.Author = value
+	case directiveAliens:
+		aliens, convErr := strconv.Atoi(value)
+		if convErr != nil || aliens < 1 {
+			err.Kind = InvalidDirective
+			r.diagnostics.report(SeverityError, err)
+			return
+		}
+		r.metadata.Aliens = aliens
+	case directiveDirections:
+		if _, lookupErr := lookupVocabulary(value); lookupErr != nil {
+			err.Kind = InvalidDirective
+			err.Err = lookupErr
+			r.diagnostics.report(SeverityError, err)
+			return
+		}
+		r.metadata.Directions = value
+	}
+}
+
+// include reads the map file at path, relative paths are resolved relative
+// to the directory of the including file
+func (r *mapReader) include(from, path string, err *ParseError) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), path)
+	}
+
+	key := includeKey(path)
+	if i := slices.IndexFunc(r.stack, func(f includedFile) bool { return f.key == key }); i >= 0 {
+		chain := make([]string, 0, len(r.stack)-i+1)
+		for _, f := range r.stack[i:] {
+			chain = append(chain, f.name)
+		}
+		err.Kind = IncludeCycle
+		err.Err = errors.New(strings.Join(append(chain, path), " -> "))
+		r.diagnostics.report(SeverityError, err)
+		return
+	}
+
+	f, openErr := os.Open(path)
+	if openErr != nil {
+		err.Kind = IncludeFailed
+		err.Err = openErr
+		r.diagnostics.report(SeverityError, err)
+		return
+	}
+	defer f.Close()
+
+	if readErr := r.read(f, path); readErr != nil {
+		err.Kind = IncludeFailed
+		err.Err = readErr
+		r.diagnostics.report(SeverityError, err)
+	}
+}
+
+// directiveValue returns the value of a directive, the tokens are unquoted
+// and joined by spaces. ok is false if a token is quoted incorrectly
+func directiveValue(tokens []token) (string, bool) {
+	values := make([]string, len(tokens))
+	for i, t := range tokens {
+		v, ok := unquote(t.text)
+		if !ok {
+			return "", false
+		}
+		values[i] = v
+	}
+	return strings.Join(values, " "), true
+}
+
+// includeKey returns the key identifying the file at path
+func includeKey(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
@@ -0,0 +1,152 @@
+package simulation
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeMaps writes the maps to files in a temporary directory and returns
+// the directory
+func writeMaps(t *testing.T, maps map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range maps {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	return dir
+}
+
+// diagnosticStrings returns the diagnostics as strings
+func diagnosticStrings(diagnostics []Diagnostic) []string {
+	actual := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		actual[i] = d.String()
+	}
+	return actual
+}
+
+func TestParseInput_comments(t *testing.T) {
+	input := `# a map with comments
+Foo north=Bar # the road to Bar
+  # an indented comment
+Bar south=Foo`
+
+	parsed, err := parseInput(strings.NewReader(input), parseConfig{})
+	require.NoError(t, err)
+	assert.Len(t, parsed.cities, 2)
+	assert.Equal(t, "Bar", parsed.cities["Foo"].neighbors[north].name)
+}
+
+func TestParseInput_metadata(t *testing.T) {
+	input := `# header
+@name "Small world"
+@author Jane Doe
+@aliens 3
+@directions compass3d
+
+Foo up=Bar
+Bar down=Foo`
+
+	parsed, err := parseInput(strings.NewReader(input), parseConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{Name: "Small world", Author: "Jane Doe", Aliens: 3, Directions: "compass3d"}, parsed.metadata)
+	assert.Empty(t, parsed.diagnostics)
+
+	// the vocabulary option takes precedence over the header
+	_, err = parseInput(strings.NewReader(input), parseConfig{vocabulary: DefaultVocabulary})
+	assert.EqualError(t, err, "invalid direction 'up' for city 'Foo'")
+}
+
+func TestValidateMap_directives(t *testing.T) {
+	input := `@name
+@aliens many
+@directions polar
+@colour red
+Foo north=Bar
+@author Jane Doe
+Bar south=Foo`
+
+	diagnostics, err := ValidateMap(strings.NewReader(input))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"1:1: error: invalid value '' for directive '@name'",
+		"2:9: error: invalid value 'many' for directive '@aliens'",
+		"3:13: error: invalid value 'polar' for directive '@directions': unknown vocabulary 'polar'",
+		"4:1: warning: unknown directive '@colour'",
+		"6:9: warning: directive '@author' is only allowed in the header of the main map, it is ignored",
+	}, diagnosticStrings(diagnostics))
+}
+
+func TestValidateMap_includes(t *testing.T) {
+	dir := writeMaps(t, map[string]string{
+		"world.map": `@name World
+@include regions/west.map
+@include regions/east.map
+Baz west=Bar`,
+		"regions/west.map": `@aliens 2
+Foo east=Bar
+Bar west=Foo east=Baz`,
+		"regions/east.map": `@include ../world.map
+Qux west=Baz`,
+	})
+
+	path := filepath.Join(dir, "world.map")
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	diagnostics, err := ValidateMap(f, WithSource(path))
+	assert.NoError(t, err)
+
+	west := filepath.Join(dir, "regions", "west.map")
+	east := filepath.Join(dir, "regions", "east.map")
+	assert.Equal(t, []string{
+		west + ":1:9: warning: directive '@aliens' is only allowed in the header of the main map, it is ignored",
+		east + ":1:10: error: include cycle: " + path + " -> " + east + " -> " + filepath.Join(dir, "regions", "..", "world.map"),
+		east + ":2:5: error: neighbor city 'Baz' has no road in direction 'east' to city 'Qux'",
+	}, diagnosticStrings(diagnostics))
+}
+
+func TestParseInput_includeFailed(t *testing.T) {
+	dir := writeMaps(t, map[string]string{"world.map": "@include missing.map\nFoo"})
+	path := filepath.Join(dir, "world.map")
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = parseInput(f, parseConfig{source: path})
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	var parseErr *ParseError
+	if assert.True(t, errors.As(err, &parseErr)) {
+		assert.Equal(t, IncludeFailed, parseErr.Kind)
+		assert.Equal(t, path, parseErr.File)
+		assert.Equal(t, 1, parseErr.Line)
+		assert.Equal(t, 10, parseErr.Column)
+	}
+}
+
+func TestParseInput_duplicateCityInInclude(t *testing.T) {
+	dir := writeMaps(t, map[string]string{
+		"world.map": "@include other.map\nFoo",
+		"other.map": "Foo",
+	})
+	path := filepath.Join(dir, "world.map")
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	diagnostics, err := ValidateMap(f, WithSource(path))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		path + ":2:1: error: duplicate city name: 'Foo', first declared at " + filepath.Join(dir, "other.map") + ":1",
+	}, diagnosticStrings(diagnostics))
+}
@@ -0,0 +1,245 @@
+package simulation
+
+import (
+	"fmt"
+	"math/rand"
+
+	"golang.org/x/exp/slices"
+)
+
+// DefaultMovementStrategy is the name of the movement strategy used when none
+// is set, aliens pick uniformly among their roads and staying
+const DefaultMovementStrategy = "uniform"
+
+// Stay is returned by a MovementStrategy for an alien staying in its city
+const Stay = ""
+
+// MovementStrategy decides where an alien moves in each iteration. It is not
+// asked for dead and trapped aliens
+type MovementStrategy interface {
+	// Move returns the direction of one of the roads in s.Roads, or Stay. rnd
+	// is the random source of the simulation, it must be the only source of
+	// randomness to keep simulations reproducible
+	Move(s Surroundings, rnd *rand.Rand) string
+}
+
+// MovementFunc is an adapter to allow the use of ordinary functions as
+// movement strategies
+type MovementFunc func(s Surroundings, rnd *rand.Rand) string
+
+// Move calls f(s, rnd)
+func (f MovementFunc) Move(s Surroundings, rnd *rand.Rand) string {
+	return f(s, rnd)
+}
+
+// Surroundings is a read-only view of the world of an alien about to move
+type Surroundings struct {
+	alien  *alien
+	cities map[string]*city
+}
+
+// Alien returns the number of the alien
+func (s Surroundings) Alien() int {
+	return s.alien.name
+}
+
+// City returns the name of the city the alien is in
+func (s Surroundings) City() string {
+	return s.alien.currentCity.name
+}
+
+// Previous returns the city the alien was in before its last move, or an
+// empty string if it hasn't moved yet
+func (s Surroundings) Previous() string {
+	if len(s.alien.visited) < 2 {
+		return ""
+	}
+	return s.alien.visited[len(s.alien.visited)-2]
+}
+
+// Roads returns the roads the alien can take, ordered by direction
+func (s Surroundings) Roads() []Road {
+	return s.alien.currentCity.roads()
+}
+
+// RoadsFrom returns the roads leading away from a city, ordered by direction.
+// Destroyed and unknown cities have no roads
+func (s Surroundings) RoadsFrom(city string) []Road {
+	c, ok := s.cities[city]
+	if !ok {
+		return nil
+	}
+	return c.roads()
+}
+
+// AliensIn returns the number of alive aliens in a city, not counting the
+// alien itself
+func (s Surroundings) AliensIn(city string) int {
+	c, ok := s.cities[city]
+	if !ok {
+		return 0
+	}
+
+	n := 0
+	for _, a := range c.visitingAliens {
+		if a != s.alien && !a.isDead() {
+			n++
+		}
+	}
+	return n
+}
+
+var movementStrategies = newRegistry[MovementStrategy]("movement strategy")
+
+func init() {
+	mustRegisterMovementStrategy(DefaultMovementStrategy, MovementFunc(uniformMovement))
+	mustRegisterMovementStrategy("never-stay", MovementFunc(neverStayMovement))
+	mustRegisterMovementStrategy("no-backtrack", MovementFunc(noBacktrackMovement))
+	mustRegisterMovementStrategy("seek-nearest-alien", MovementFunc(seekNearestAlienMovement))
+	mustRegisterMovementStrategy("avoid-aliens", MovementFunc(avoidAliensMovement))
+}
+
+// RegisterMovementStrategy registers a movement strategy under name, so it
+// can be used with WithMovementStrategy and WithAlienMovementStrategy
+func RegisterMovementStrategy(name string, strategy MovementStrategy) error {
+	if name != "" && strategy == nil {
+		return fmt.Errorf("movement strategy '%s' is nil", name)
+	}
+	return movementStrategies.register(name, strategy)
+}
+
+// MovementStrategies returns the names of all registered movement strategies
+// in sorted order
+func MovementStrategies() []string {
+	return movementStrategies.names()
+}
+
+// lookupMovementStrategy returns the movement strategy registered under name,
+// the default strategy is returned if name is empty
+func lookupMovementStrategy(name string) (MovementStrategy, error) {
+	if name == "" {
+		name = DefaultMovementStrategy
+	}
+
+	return movementStrategies.lookup(name)
+}
+
+func mustRegisterMovementStrategy(name string, strategy MovementStrategy) {
+	if err := RegisterMovementStrategy(name, strategy); err != nil {
+		panic(err)
+	}
+}
+
+// pickRoad returns the direction of a random road, or Stay if stay is set and
+// staying is picked. Staying is as likely as each road
+func pickRoad(roads []Road, stay bool, rnd *rand.Rand) string {
+	options := len(roads)
+	if stay {
+		options++
+	}
+	if options == 0 {
+		return Stay
+	}
+
+	i := rnd.Intn(options)
+	if stay {
+		// staying is the first option
+		i--
+	}
+	if i < 0 {
+		return Stay
+	}
+	return roads[i].Direction
+}
+
+// uniformMovement picks uniformly among the roads and staying
+func uniformMovement(s Surroundings, rnd *rand.Rand) string {
+	return pickRoad(s.Roads(), true, rnd)
+}
+
+// neverStayMovement picks uniformly among the roads, the alien only stays if
+// there is none
+func neverStayMovement(s Surroundings, rnd *rand.Rand) string {
+	return pickRoad(s.Roads(), false, rnd)
+}
+
+// noBacktrackMovement picks uniformly among the roads and staying, but doesn't
+// take a road back to the previous city unless it is the only road
+func noBacktrackMovement(s Surroundings, rnd *rand.Rand) string {
+	roads := s.Roads()
+	forward := make([]Road, 0, len(roads))
+	for _, r := range roads {
+		if r.City != s.Previous() {
+			forward = append(forward, r)
+		}
+	}
+	if len(forward) == 0 {
+		forward = roads
+	}
+	return pickRoad(forward, true, rnd)
+}
+
+// seekNearestAlienMovement takes the first road of a shortest path to the
+// nearest city with another alien, ties are broken randomly. The alien stays
+// if another alien has already moved to its city, and moves like with
+// uniformMovement if no other alien is reachable
+func seekNearestAlienMovement(s Surroundings, rnd *rand.Rand) string {
+	if s.AliensIn(s.City()) > 0 {
+		return Stay
+	}
+
+	// breadth-first search, remembering the first road taken to each city
+	firstRoads := map[string]Road{s.City(): {}}
+	var level []string
+	for _, r := range s.Roads() {
+		if _, seen := firstRoads[r.City]; !seen {
+			firstRoads[r.City] = r
+			level = append(level, r.City)
+		}
+	}
+
+	for len(level) > 0 {
+		var found []Road
+		for _, c := range level {
+			if s.AliensIn(c) > 0 && !slices.Contains(found, firstRoads[c]) {
+				found = append(found, firstRoads[c])
+			}
+		}
+		if len(found) > 0 {
+			slices.SortFunc(found, func(a, b Road) bool { return a.Direction < b.Direction })
+			return pickRoad(found, false, rnd)
+		}
+
+		var next []string
+		for _, c := range level {
+			for _, r := range s.RoadsFrom(c) {
+				if _, seen := firstRoads[r.City]; !seen {
+					firstRoads[r.City] = firstRoads[c]
+					next = append(next, r.City)
+				}
+			}
+		}
+		level = next
+	}
+
+	return uniformMovement(s, rnd)
+}
+
+// avoidAliensMovement picks uniformly among the roads to cities without other
+// aliens, and staying if there is no other alien in the city. The alien moves
+// like with uniformMovement if every road leads to other aliens, so aliens
+// blocking each other don't stay forever
+func avoidAliensMovement(s Surroundings, rnd *rand.Rand) string {
+	roads := s.Roads()
+	safe := make([]Road, 0, len(roads))
+	for _, r := range roads {
+		if s.AliensIn(r.City) == 0 {
+			safe = append(safe, r)
+		}
+	}
+
+	if len(safe) == 0 {
+		return uniformMovement(s, rnd)
+	}
+	return pickRoad(safe, s.AliensIn(s.City()) == 0, rnd)
+}
@@ -0,0 +1,176 @@
+package simulation
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainMap is a map of four cities in a row, from A in the west to D in the
+// east
+const chainMap = `{
+  "cities": [
+    {"name": "A", "roads": [{"direction": "east", "city": "B"}]},
+    {"name": "B", "roads": [{"direction": "west", "city": "A"}, {"direction": "east", "city": "C"}]},
+    {"name": "C", "roads": [{"direction": "west", "city": "B"}, {"direction": "east", "city": "D"}]},
+    {"name": "D", "roads": [{"direction": "west", "city": "C"}]}
+  ],
+  "placements": [%s]
+}`
+
+// newChainSimulation returns a simulation of chainMap with the aliens placed
+// in the given cities, alien 1 in the first city
+func newChainSimulation(t *testing.T, cities ...string) *Simulation {
+	placements := make([]string, len(cities))
+	for i, c := range cities {
+		placements[i] = `{"alien": ` + strconv.Itoa(i+1) + `, "city": "` + c + `"}`
+	}
+	input := strings.Replace(chainMap, "%s", strings.Join(placements, ", "), 1)
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens, WithSeed(1))
+	require.NoError(t, err)
+	return sim
+}
+
+// moves returns the distinct directions picked by the strategy for alien 1
+// in 100 draws
+func moves(sim *Simulation, strategy string) map[string]bool {
+	s, _ := lookupMovementStrategy(strategy)
+	rnd := rand.New(rand.NewSource(1))
+
+	picked := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		picked[s.Move(Surroundings{alien: sim.aliens[0], cities: sim.cities}, rnd)] = true
+	}
+	return picked
+}
+
+func TestMovementStrategies(t *testing.T) {
+	assert.Subset(t, MovementStrategies(), []string{"avoid-aliens", "never-stay", "no-backtrack", "seek-nearest-alien", "uniform"})
+}
+
+func TestRegisterMovementStrategy(t *testing.T) {
+	west := MovementFunc(func(Surroundings, *rand.Rand) string { return "west" })
+	t.Cleanup(func() { movementStrategies.unregister("test-west") })
+	assert.NoError(t, RegisterMovementStrategy("test-west", west))
+
+	testCases := []struct {
+		name          string
+		strategy      string
+		movement      MovementStrategy
+		expectedError string
+	}{
+		{"empty name", "", west, "movement strategy name must not be empty"},
+		{"nil strategy", "test-nil", nil, "movement strategy 'test-nil' is nil"},
+		{"already registered", "test-west", west, "movement strategy 'test-west' is already registered"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := RegisterMovementStrategy(tc.strategy, tc.movement)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+
+	sim := newChainSimulation(t, "B")
+	assert.Equal(t, map[string]bool{"west": true}, moves(sim, "test-west"))
+}
+
+func TestSurroundings(t *testing.T) {
+	sim := newChainSimulation(t, "B", "C", "C")
+	s := Surroundings{alien: sim.aliens[1], cities: sim.cities}
+
+	assert.Equal(t, 2, s.Alien())
+	assert.Equal(t, "C", s.City())
+	assert.Equal(t, "", s.Previous(), "expected no previous city before the first move")
+	assert.Equal(t, []Road{{Direction: "east", City: "D"}, {Direction: "west", City: "B"}}, s.Roads())
+	assert.Equal(t, []Road{{Direction: "east", City: "B"}}, s.RoadsFrom("A"))
+	assert.Empty(t, s.RoadsFrom("unknown"))
+	assert.Equal(t, 1, s.AliensIn("B"))
+	assert.Equal(t, 1, s.AliensIn("C"), "expected the alien itself to not be counted")
+	assert.Equal(t, 0, s.AliensIn("D"))
+
+	sim.aliens[1].goToCity(sim.cities["D"])
+	assert.Equal(t, "C", s.Previous())
+}
+
+func TestMovementStrategy_builtins(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy string
+		cities   []string
+		// visited are the cities alien 1 has been in before its current city
+		visited  []string
+		expected map[string]bool
+	}{
+		{"uniform", "uniform", []string{"B"}, nil, map[string]bool{Stay: true, "east": true, "west": true}},
+		{"never stay", "never-stay", []string{"B"}, nil, map[string]bool{"east": true, "west": true}},
+		{"no backtrack", "no-backtrack", []string{"B"}, []string{"A"}, map[string]bool{Stay: true, "east": true}},
+		{"no backtrack at a dead end", "no-backtrack", []string{"A"}, []string{"B"}, map[string]bool{Stay: true, "east": true}},
+		{"seek nearest alien", "seek-nearest-alien", []string{"A", "D"}, nil, map[string]bool{"east": true}},
+		{"seek nearest alien on both sides", "seek-nearest-alien", []string{"B", "A", "C"}, nil, map[string]bool{"east": true, "west": true}},
+		{"seek nearest alien in the same city", "seek-nearest-alien", []string{"B", "B", "D"}, nil, map[string]bool{Stay: true}},
+		{"seek without other aliens", "seek-nearest-alien", []string{"B"}, nil, map[string]bool{Stay: true, "east": true, "west": true}},
+		{"avoid aliens", "avoid-aliens", []string{"B", "C"}, nil, map[string]bool{Stay: true, "west": true}},
+		{"avoid aliens in the same city", "avoid-aliens", []string{"B", "B"}, nil, map[string]bool{"east": true, "west": true}},
+		{"avoid aliens everywhere", "avoid-aliens", []string{"B", "A", "C"}, nil, map[string]bool{Stay: true, "east": true, "west": true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := newChainSimulation(t, tc.cities...)
+			sim.aliens[0].visited = append(tc.visited, sim.aliens[0].visited...)
+
+			assert.Equal(t, tc.expected, moves(sim, tc.strategy))
+		})
+	}
+}
+
+func TestNewSimulation_withMovementStrategy(t *testing.T) {
+	input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "A"}, {"alien": 2, "city": "D"}`, 1)
+
+	// aliens seeking each other meet in the middle, alien 1 moves first
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens, WithMovementStrategy("seek-nearest-alien"))
+	require.NoError(t, err)
+	state, err := sim.Run()
+	require.NoError(t, err)
+	assert.Equal(t, SimStateAllAliensDeadOrTrapped, state)
+	assert.Equal(t, 2, sim.iteration)
+	assert.True(t, sim.cities["C"].isDestroyed(), "expected the aliens to meet in C")
+
+	// an alien that never moves is found by the other
+	never := MovementFunc(func(Surroundings, *rand.Rand) string { return Stay })
+	t.Cleanup(func() { movementStrategies.unregister("test-never-move") })
+	require.NoError(t, RegisterMovementStrategy("test-never-move", never))
+	sim, err = NewSimulation(strings.NewReader(input), MapAliens,
+		WithMovementStrategy("seek-nearest-alien"),
+		WithAlienMovementStrategy(2, "test-never-move"),
+	)
+	require.NoError(t, err)
+	_, err = sim.Run()
+	require.NoError(t, err)
+	assert.Equal(t, 3, sim.iteration)
+	assert.True(t, sim.cities["D"].isDestroyed(), "expected alien 1 to find alien 2 in D")
+
+	_, err = NewSimulation(strings.NewReader(input), MapAliens, WithMovementStrategy("unknown"))
+	assert.EqualError(t, err, "unknown movement strategy 'unknown'")
+
+	_, err = NewSimulation(strings.NewReader(input), MapAliens, WithAlienMovementStrategy(2, "unknown"))
+	assert.EqualError(t, err, "alien 2: unknown movement strategy 'unknown'")
+
+	_, err = NewSimulation(strings.NewReader(input), MapAliens, WithAlienMovementStrategy(3, "uniform"))
+	assert.EqualError(t, err, "movement strategy 'uniform' set for alien 3, but there is no such alien")
+
+	// a strategy picking a road the city doesn't have stops the simulation
+	west := MovementFunc(func(Surroundings, *rand.Rand) string { return "west" })
+	t.Cleanup(func() { movementStrategies.unregister("test-always-west") })
+	require.NoError(t, RegisterMovementStrategy("test-always-west", west))
+	sim, err = NewSimulation(strings.NewReader(input), MapAliens, WithMovementStrategy("test-always-west"))
+	require.NoError(t, err)
+	_, err = sim.Step()
+	assert.EqualError(t, err, "failed to move alien: movement strategy of alien 1 picked direction 'west', but city 'A' has no road in that direction")
+}
@@ -0,0 +1,60 @@
+package simulation
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// quote is the character enclosing quoted city names in the map format
+const quote = '"'
+
+// parseName returns the city name written as raw in the map format. Quoted
+// names are unquoted using Go's escaping rules, e.g. "New York" or
+// "São Paulo". Names are normalized to NFC so that equivalent names
+// match. ok is false if the quoting is invalid
+func parseName(raw string) (name string, ok bool) {
+	name, ok = unquote(raw)
+	if !ok {
+		return "", false
+	}
+	return normalizeName(name), true
+}
+
+// normalizeName returns the name in NFC, the form names are compared in
+func normalizeName(name string) string {
+	return norm.NFC.String(name)
+}
+
+// unquote returns raw without quotes if it is quoted. ok is false if the
+// quoting is invalid
+func unquote(raw string) (s string, ok bool) {
+	if strings.HasPrefix(raw, string(quote)) {
+		unquoted, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", false
+		}
+		return unquoted, true
+	}
+
+	// quotes can only enclose the whole token
+	return raw, !strings.ContainsRune(raw, quote)
+}
+
+// quoteName returns the name as it has to be written in the map format, names
+// that wouldn't be read back as the same name are quoted
+func quoteName(name string) string {
+	// names starting with a comment or directive would be read as such
+	needsQuotes := name == "" ||
+		strings.HasPrefix(name, string(comment)) ||
+		strings.HasPrefix(name, directivePrefix) ||
+		strings.IndexFunc(name, func(r rune) bool {
+			return r == quote || unicode.IsSpace(r) || !unicode.IsPrint(r)
+		}) >= 0
+	if needsQuotes {
+		return strconv.Quote(name)
+	}
+	return name
+}
@@ -0,0 +1,82 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseName(t *testing.T) {
+	testCases := []struct {
+		raw      string
+		expected string
+		ok       bool
+	}{
+		{`Foo`, "Foo", true},
+		{`"New York"`, "New York", true},
+		{`"Say \"hi\""`, `Say "hi"`, true},
+		{`"Tab\tCity"`, "Tab\tCity", true},
+		// decomposed ã is normalized to the composed form
+		{"Sa\u0303o", "S\u00e3o", true},
+		{`"New York`, "", false},
+		{`"New" York`, "", false},
+		{`Foo"s`, "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.raw, func(t *testing.T) {
+			actual, ok := parseName(tc.raw)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestQuoteName(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"Foo", "Foo"},
+		{"São_Paulo", "São_Paulo"},
+		{"New York", `"New York"`},
+		{"São Paulo", `"São Paulo"`},
+		{`Say "hi"`, `"Say \"hi\""`},
+		{"", `""`},
+		{"#1", `"#1"`},
+		{"@home", `"@home"`},
+		{"Foo#1", "Foo#1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := quoteName(tc.name)
+			assert.Equal(t, tc.expected, actual)
+
+			parsed, ok := parseName(actual)
+			assert.True(t, ok, "expected quoted name to be parsable")
+			assert.Equal(t, tc.name, parsed, "expected quoted name to round-trip")
+		})
+	}
+}
+
+func TestParseInput_quotedNames(t *testing.T) {
+	// the second São Paulo is written with an escaped, decomposed ã
+	input := `"New York" north="Los Angeles" east="São Paulo"
+"Los Angeles" south="New York"
+"Sa\u0303o Paulo" west="New York"`
+
+	m, err := parseInput(strings.NewReader(input), parseConfig{})
+	assert.NoError(t, err, "expected quoted and equivalent names to match")
+
+	output := (&Simulation{}).CitiesToString(m.cities)
+	assert.Equal(t, `"Los Angeles" south="New York"
+"New York" east="São Paulo" north="Los Angeles"
+"São Paulo" west="New York"
+`, output)
+
+	roundTrip, err := parseInput(strings.NewReader(output), parseConfig{})
+	assert.NoError(t, err, "expected the output to be parsable")
+	assert.Equal(t, output, (&Simulation{}).CitiesToString(roundTrip.cities), "expected the output to round-trip")
+}
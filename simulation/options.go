@@ -0,0 +1,177 @@
+package simulation
+
+import (
+	"math/rand"
+
+	"golang.org/x/exp/slog"
+)
+
+// DefaultMaxMoves is the number of moves each alien is allowed to make before
+// the simulation ends, as specified by the task description
+const DefaultMaxMoves = 10000
+
+// Option configures a Simulation created by NewSimulation
+type Option func(*Simulation)
+
+// WithMaxIterations caps the number of iterations the simulation runs for. A
+// value of 0 or less disables the cap
+func WithMaxIterations(n int) Option {
+	return func(s *Simulation) {
+		s.maxIterations = n
+	}
+}
+
+// WithMaxMoves caps the number of moves each alien is allowed to make, staying
+// in a city counts as a move. Once every alien that is still able to move has
+// reached the cap, the simulation ends. A value of 0 or less disables the cap
+func WithMaxMoves(n int) Option {
+	return func(s *Simulation) {
+		s.maxMoves = n
+	}
+}
+
+// WithSeed seeds the random source used for placing and moving aliens. Two
+// simulations created from the same input, number of aliens and seed produce
+// the same result
+func WithSeed(seed int64) Option {
+	return func(s *Simulation) {
+		s.seed = seed
+		s.rnd = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithRandSource sets the random source used for placing and moving aliens.
+// The seed reported by Simulation.Seed is meaningless when a custom source is
+// used
+func WithRandSource(src rand.Source) Option {
+	return func(s *Simulation) {
+		s.seed = 0
+		s.rnd = rand.New(src)
+	}
+}
+
+// WithLogger sets the logger used for diagnostics. Per-iteration details are
+// logged at debug level, interruptions at warn level. slog.Default is used
+// when no logger is set
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Simulation) {
+		s.logger = logger
+	}
+}
+
+// WithRepair makes asymmetric roads in the map symmetric instead of rejecting
+// the map. Missing reverse roads are added, and roads whose reverse direction
+// already leads to another city are dropped. The changes are available from
+// Simulation.Repairs
+func WithRepair() Option {
+	return func(s *Simulation) {
+		s.parse.repair = true
+	}
+}
+
+// WithVocabulary sets the vocabulary of directions the roads in the map can
+// have, see Vocabularies for the registered vocabularies. DefaultVocabulary
+// is used when not set
+func WithVocabulary(name string) Option {
+	return func(s *Simulation) {
+		s.parse.vocabulary = name
+	}
+}
+
+// WithMovementStrategy sets the movement strategy of all aliens, see
+// MovementStrategies for the registered strategies. DefaultMovementStrategy is
+// used when not set
+func WithMovementStrategy(name string) Option {
+	return func(s *Simulation) {
+		s.movement = name
+	}
+}
+
+// WithAlienMovementStrategy sets the movement strategy of a single alien,
+// overriding the strategy set by WithMovementStrategy
+func WithAlienMovementStrategy(alien int, name string) Option {
+	return func(s *Simulation) {
+		if s.alienMovement == nil {
+			s.alienMovement = make(map[int]string)
+		}
+		s.alienMovement[alien] = name
+	}
+}
+
+// WithBattleRule sets the rule deciding the outcome of battles, see
+// BattleRules for the registered rules. DefaultBattleRule is used when not set
+func WithBattleRule(name string) Option {
+	return func(s *Simulation) {
+		s.battleRule = name
+	}
+}
+
+// WithMinCombatants sets the number of aliens in a city needed for a battle,
+// it must be at least 2. DefaultMinCombatants is used when not set
+func WithMinCombatants(n int) Option {
+	return func(s *Simulation) {
+		s.minCombatants = n
+	}
+}
+
+// WithAlienStrength sets the strength of a single alien, which some battle
+// rules take into account. It must be greater than 0, aliens have
+// DefaultStrength when not set
+func WithAlienStrength(alien int, strength float64) Option {
+	return func(s *Simulation) {
+		if s.strengths == nil {
+			s.strengths = make(map[int]float64)
+		}
+		s.strengths[alien] = strength
+	}
+}
+
+// WithPlacement sets how the aliens not placed by the map or a placement file
+// are placed, PlacementUniform is used when not set
+func WithPlacement(cfg PlacementConfig) Option {
+	return func(s *Simulation) {
+		s.placement = cfg
+	}
+}
+
+// WithPlacementFile reads the placements of the aliens from the placement
+// file at path, replacing the placements of the map. Each line places an
+// alien in a city, e.g. "alien 3 Foo", names can be quoted as in maps and #
+// starts a comment
+func WithPlacementFile(path string) Option {
+	return func(s *Simulation) {
+		s.parse.placementFile = path
+	}
+}
+
+// WithSimultaneousMoves lets all aliens pick their move before any alien
+// moves, so the moves don't depend on the order of the aliens. Aliens moving
+// along the same road in opposite directions cross each other, crossing
+// decides what happens to them. CrossingBattle is used if crossing is empty
+func WithSimultaneousMoves(crossing CrossingOutcome) Option {
+	return func(s *Simulation) {
+		s.simultaneous = true
+		s.crossing = crossing
+	}
+}
+
+// WithSource sets the name of the map file read by NewSimulation. It is used
+// as File in diagnostics and errors, and maps included by the map are
+// resolved relative to it. Includes are resolved relative to the working
+// directory when not set. A map whose name ends in ".json" is always read as
+// a JSON map
+func WithSource(name string) Option {
+	return func(s *Simulation) {
+		s.parse.source = name
+	}
+}
+
+// parseOptions returns the parse configuration set by opts, for functions
+// which parse a map without creating a simulation
+func parseOptions(opts []Option) parseConfig {
+	sim := &Simulation{}
+	for _, opt := range opts {
+		opt(sim)
+	}
+	return sim.parse
+}
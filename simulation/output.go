@@ -0,0 +1,153 @@
+package simulation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OutputSchemaVersion is the version of the JSON and CSV output schemas. It is
+// increased whenever a field or column is renamed, removed or changes meaning,
+// adding new fields or trailing columns doesn't change the version
+const OutputSchemaVersion = 1
+
+// csvHeader contains the columns of the CSV output, see WriteCSV
+var csvHeader = []string{
+	"schema_version",
+	"record",
+	"name",
+	"status",
+	"iteration",
+	"city",
+	"aliens",
+	"moves",
+	"roads",
+	"visited",
+}
+
+// jsonOutput is the document written by WriteJSON
+type jsonOutput struct {
+	SchemaVersion int `json:"schema_version"`
+	Result
+}
+
+// WriteJSON writes the result of the simulation to w as a JSON document:
+//
+//	{
+//	  "schema_version": 1,
+//	  "seed": 42,
+//	  "iterations": 7,
+//	  "state": "STATE_ALL_ALIENS_DEAD_OR_TRAPPED",
+//	  "surviving_cities": [{"city": "Bar", "roads": [{"direction": "west", "city": "Fex"}]}],
+//	  "destroyed_cities": [{"city": "Baz", "iteration": 7, "aliens": [1, 2]}],
+//	  "aliens": [{"alien": 1, "moves": 5, "visited": ["Qux", "Baz"], "fate": "dead", "fate_iteration": 7, "fate_city": "Baz"}]
+//	}
+func (s *Simulation) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(jsonOutput{SchemaVersion: OutputSchemaVersion, Result: s.Result()}); err != nil {
+		return fmt.Errorf("failed to write JSON output: %w", err)
+	}
+
+	return nil
+}
+
+// WriteCSV writes the result of the simulation to w as CSV with a header row,
+// followed by one row per city ordered by name and one row per alien ordered
+// by name. The columns are:
+//
+//	schema_version  always OutputSchemaVersion
+//	record          "city" or "alien"
+//	name            the city name or the alien number
+//	status          "survived" or "destroyed" for cities, the AlienFate for aliens
+//	iteration       the iteration a city was destroyed or an alien died or became trapped
+//	city            the city an alien is in, died in or is trapped in
+//	aliens          the aliens that destroyed a city, separated by spaces
+//	moves           the number of moves an alien made
+//	roads           the remaining roads of a city in the map format, e.g. "north=Bar west->Baz"
+//	visited         the cities an alien has been in, separated by spaces
+//
+// City names in the roads and visited columns are quoted as in the map
+// format if needed, e.g. "New York"
+//
+// Columns that don't apply to a record are left empty
+func (s *Simulation) WriteCSV(w io.Writer) error {
+	result := s.Result()
+	version := strconv.Itoa(OutputSchemaVersion)
+
+	records := [][]string{csvHeader}
+
+	destroyed := make(map[string]DestroyedCity, len(result.DestroyedCities))
+	for _, d := range result.DestroyedCities {
+		destroyed[d.City] = d
+	}
+	surviving := make(map[string]SurvivingCity, len(result.SurvivingCities))
+	for _, c := range result.SurvivingCities {
+		surviving[c.City] = c
+	}
+
+	for _, cityName := range s.sortedCityNames() {
+		if d, ok := destroyed[cityName]; ok {
+			records = append(records, []string{
+				version, "city", cityName, "destroyed", strconv.Itoa(d.Iteration), "", joinInts(d.Aliens), "", "", "",
+			})
+			continue
+		}
+
+		roads := make([]string, len(surviving[cityName].Roads))
+		for i, road := range surviving[cityName].Roads {
+			separator := twoWaySeparator
+			if road.OneWay {
+				separator = oneWaySeparator
+			}
+			roads[i] = road.Direction + separator + quoteName(road.City)
+		}
+		records = append(records, []string{
+			version, "city", cityName, "survived", "", "", "", "", strings.Join(roads, " "), "",
+		})
+	}
+
+	for _, a := range result.Aliens {
+		iteration := ""
+		city := a.FateCity
+		if a.Fate == FateAlive {
+			if len(a.Visited) > 0 {
+				city = a.Visited[len(a.Visited)-1]
+			}
+		} else {
+			iteration = strconv.Itoa(a.FateIteration)
+		}
+
+		records = append(records, []string{
+			version, "alien", strconv.Itoa(a.Alien), string(a.Fate), iteration, city, "", strconv.Itoa(a.Moves), "", joinNames(a.Visited),
+		})
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write CSV output: %w", err)
+	}
+
+	return nil
+}
+
+func joinInts(values []int) string {
+	strs := make([]string, len(values))
+	for i, v := range values {
+		strs[i] = strconv.Itoa(v)
+	}
+	return strings.Join(strs, " ")
+}
+
+// joinNames joins city names with spaces, quoting them as in the map format
+func joinNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteName(name)
+	}
+	return strings.Join(quoted, " ")
+}
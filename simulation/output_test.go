@@ -0,0 +1,75 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newOutputSimulation returns a simulation in which aliens 2 and 3 destroy
+// City2 in the first iteration, alien 1 gets trapped in City1 and alien 4
+// stays alive in City3
+func newOutputSimulation(t *testing.T) *Simulation {
+	return newSettledSimulation(t, "City1 north=City2\nCity2 south=City1\nCity3 east=City4\nCity4 west=City3",
+		[]string{"City2", "City1"},
+		[]string{"City1", "City2"},
+		[]string{"City2"},
+		[]string{"City4", "City3"},
+	)
+}
+
+func TestSimulation_WriteJSON(t *testing.T) {
+	sim := newOutputSimulation(t)
+	_, err := sim.Run()
+	assert.NoError(t, err, "expected no error when running simulation")
+
+	builder := &strings.Builder{}
+	err = sim.WriteJSON(builder)
+	assert.NoError(t, err, "expected no error when writing JSON output")
+	assert.JSONEq(t, `{
+		"schema_version": 1,
+		"seed": 1,
+		"iterations": 1,
+		"state": "STATE_ALIVE_ALIENS_DISCONNECTED",
+		"surviving_cities": [
+			{"city": "City1", "roads": []},
+			{"city": "City3", "roads": [{"direction": "east", "city": "City4"}]},
+			{"city": "City4", "roads": [{"direction": "west", "city": "City3"}]}
+		],
+		"destroyed_cities": [
+			{"city": "City2", "iteration": 1, "aliens": [2, 3]}
+		],
+		"aliens": [
+			{"alien": 1, "moves": 1, "visited": ["City2", "City1"], "fate": "trapped", "fate_iteration": 1, "fate_city": "City1"},
+			{"alien": 2, "moves": 1, "visited": ["City1", "City2"], "fate": "dead", "fate_iteration": 1, "fate_city": "City2"},
+			{"alien": 3, "moves": 1, "visited": ["City2"], "fate": "dead", "fate_iteration": 1, "fate_city": "City2"},
+			{"alien": 4, "moves": 1, "visited": ["City4", "City3"], "fate": "alive"}
+		]
+	}`, builder.String())
+}
+
+func TestSimulation_WriteCSV(t *testing.T) {
+	sim := newOutputSimulation(t)
+	_, err := sim.Run()
+	assert.NoError(t, err, "expected no error when running simulation")
+
+	builder := &strings.Builder{}
+	err = sim.WriteCSV(builder)
+	assert.NoError(t, err, "expected no error when writing CSV output")
+	assert.Equal(t, `schema_version,record,name,status,iteration,city,aliens,moves,roads,visited
+1,city,City1,survived,,,,,,
+1,city,City2,destroyed,1,,2 3,,,
+1,city,City3,survived,,,,,east=City4,
+1,city,City4,survived,,,,,west=City3,
+1,alien,1,trapped,1,City1,,1,,City2 City1
+1,alien,2,dead,1,City2,,1,,City1 City2
+1,alien,3,dead,1,City2,,1,,City2
+1,alien,4,alive,,City3,,1,,City4 City3
+`, builder.String())
+}
+
+func TestJoinNames(t *testing.T) {
+	assert.Equal(t, `Foo "New York" Bar`, joinNames([]string{"Foo", "New York", "Bar"}))
+	assert.Equal(t, "", joinNames(nil))
+}
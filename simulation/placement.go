@@ -0,0 +1,293 @@
+package simulation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// PlacementMode selects how NewSimulation places the aliens that are not
+// placed by the map or a placement file. Aliens landing in the same city fight
+// at the start of the first iteration, before any alien moves
+type PlacementMode string
+
+// placement modes
+const (
+	// every alien lands in a random city, several aliens may land in the same
+	// city
+	PlacementUniform PlacementMode = "uniform"
+	// every alien lands in a random city without another alien
+	PlacementUnique PlacementMode = "unique"
+	// aliens land in random cities, the chance of each city is proportional
+	// to the numeric attribute PlacementConfig.Attribute of the city in a
+	// JSON map
+	PlacementWeighted PlacementMode = "weighted"
+	// aliens land in random cities at most PlacementConfig.Radius roads away
+	// from PlacementConfig.Center, following roads in their direction only
+	PlacementClustered PlacementMode = "clustered"
+	// aliens are only placed by the map or a placement file, every alien
+	// must be placed
+	PlacementExplicit PlacementMode = "explicit"
+)
+
+// PlacementModes returns the names of all placement modes
+func PlacementModes() []string {
+	return []string{
+		string(PlacementUniform),
+		string(PlacementUnique),
+		string(PlacementWeighted),
+		string(PlacementClustered),
+		string(PlacementExplicit),
+	}
+}
+
+// PlacementConfig configures how aliens are placed, see WithPlacement
+type PlacementConfig struct {
+	Mode PlacementMode
+	// Attribute is the city attribute weighting the cities for
+	// PlacementWeighted
+	Attribute string
+	// Center and Radius are the city the aliens are clustered around and
+	// the maximum number of roads between the center and the cities the
+	// aliens land in for PlacementClustered
+	Center string
+	Radius int
+}
+
+// Validate returns an error if the config cannot be used to place aliens,
+// independent of the map
+func (cfg PlacementConfig) Validate() error {
+	switch cfg.Mode {
+	case "", PlacementUniform, PlacementUnique, PlacementExplicit:
+	case PlacementWeighted:
+		if cfg.Attribute == "" {
+			return fmt.Errorf("%s placement requires a city attribute", cfg.Mode)
+		}
+	case PlacementClustered:
+		if cfg.Center == "" {
+			return fmt.Errorf("%s placement requires a center city", cfg.Mode)
+		}
+		if cfg.Radius < 0 {
+			return fmt.Errorf("radius must not be negative, got %d", cfg.Radius)
+		}
+	default:
+		return fmt.Errorf("unknown placement mode: %s", cfg.Mode)
+	}
+	return nil
+}
+
+// placementKeyword starts a line of a placement file
+const placementKeyword = "alien"
+
+// readPlacementFile reads the placements from the placement file at path.
+// Each line places an alien in a city, e.g. "alien 3 Foo", names can be
+// quoted as in maps and # starts a comment
+func readPlacementFile(path string, report func(Severity, *ParseError)) ([]placementDecl, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open placement file: %w", err)
+	}
+	defer f.Close()
+
+	var placements []placementDecl
+	scanner := bufio.NewScanner(f)
+	lineNr := 0
+	for scanner.Scan() {
+		lineNr++
+
+		tokens := tokenize(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+
+		err := &ParseError{File: path, Line: lineNr, Column: tokens[0].column}
+		if len(tokens) != 3 || tokens[0].text != placementKeyword {
+			err.Kind = MalformedPlacement
+			err.Token = strings.TrimSpace(scanner.Text()[tokens[0].column-1:])
+			report(SeverityError, err)
+			continue
+		}
+
+		alien, convErr := strconv.Atoi(tokens[1].text)
+		if convErr != nil {
+			err.Kind = MalformedPlacement
+			err.Column = tokens[1].column
+			err.Token = tokens[1].text
+			err.Err = errors.New("the alien must be a number")
+			report(SeverityError, err)
+			continue
+		}
+
+		name, ok := parseName(tokens[2].text)
+		if !ok {
+			err.Kind = MalformedName
+			err.Column = tokens[2].column
+			err.Token = tokens[2].text
+			report(SeverityError, err)
+			continue
+		}
+
+		placements = append(placements, placementDecl{
+			pos:       roadPosition{file: path, line: lineNr, column: tokens[0].column, token: tokens[0].text},
+			Placement: Placement{Alien: alien, City: name},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read placement file: %w", err)
+	}
+
+	return placements, nil
+}
+
+// placeAliens places the aliens in the cities set by placed, and the others
+// according to the placement config. The aliens land in order, so aliens in
+// the same city are ordered by name
+func (s *Simulation) placeAliens(aliens []*alien, placed map[int]string) error {
+	targets, err := s.placementTargets(aliens, placed)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range aliens {
+		a.land(targets[a.name])
+	}
+	return nil
+}
+
+// placementTargets returns the city each alien lands in
+func (s *Simulation) placementTargets(aliens []*alien, placed map[int]string) (map[int]*city, error) {
+	targets := make(map[int]*city, len(aliens))
+	var unplaced []*alien
+	for _, a := range aliens {
+		name, ok := placed[a.name]
+		if !ok {
+			unplaced = append(unplaced, a)
+			continue
+		}
+		targets[a.name] = s.cities[name]
+	}
+	if len(unplaced) == 0 {
+		return targets, nil
+	}
+
+	cityNames := s.sortedCityNames()
+	switch s.placement.Mode {
+	case "", PlacementUniform:
+		for _, a := range unplaced {
+			targets[a.name] = s.cities[cityNames[s.rnd.Intn(len(cityNames))]]
+		}
+	case PlacementUnique:
+		occupied := make(map[string]bool, len(placed))
+		for _, name := range placed {
+			occupied[name] = true
+		}
+		free := make([]string, 0, len(cityNames))
+		for _, name := range cityNames {
+			if !occupied[name] {
+				free = append(free, name)
+			}
+		}
+		if len(unplaced) > len(free) {
+			return nil, fmt.Errorf("cannot place %d aliens in %d cities without aliens", len(unplaced), len(free))
+		}
+		for _, a := range unplaced {
+			i := s.rnd.Intn(len(free))
+			targets[a.name] = s.cities[free[i]]
+			free[i] = free[len(free)-1]
+			free = free[:len(free)-1]
+		}
+	case PlacementWeighted:
+		weighted, cumulative, err := s.cityWeights(cityNames, s.placement.Attribute)
+		if err != nil {
+			return nil, err
+		}
+		total := cumulative[len(cumulative)-1]
+		for _, a := range unplaced {
+			// the first city whose cumulative weight exceeds the draw
+			draw := s.rnd.Float64() * total
+			i := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > draw })
+			targets[a.name] = s.cities[weighted[i]]
+		}
+	case PlacementClustered:
+		center, ok := s.cities[s.placement.Center]
+		if !ok {
+			return nil, fmt.Errorf("unknown center city '%s'", s.placement.Center)
+		}
+		cluster := center.withinRadius(s.placement.Radius)
+		for _, a := range unplaced {
+			targets[a.name] = s.cities[cluster[s.rnd.Intn(len(cluster))]]
+		}
+	case PlacementExplicit:
+		return nil, fmt.Errorf("alien %d is not placed by the map or the placement file", unplaced[0].name)
+	default:
+		return nil, fmt.Errorf("unknown placement mode: %s", s.placement.Mode)
+	}
+
+	return targets, nil
+}
+
+// cityWeights returns the cities with a positive weight and their cumulative
+// weights, the weight of a city is its numeric attribute. Cities without the
+// attribute have no weight
+func (s *Simulation) cityWeights(cityNames []string, attribute string) ([]string, []float64, error) {
+	var weighted []string
+	var cumulative []float64
+	total := 0.0
+	for _, name := range cityNames {
+		value, ok := s.cities[name].attributes[attribute]
+		if !ok {
+			continue
+		}
+		weight, ok := value.(float64)
+		if !ok {
+			return nil, nil, fmt.Errorf("attribute '%s' of city '%s' is not a number", attribute, name)
+		}
+		if weight < 0 {
+			return nil, nil, fmt.Errorf("attribute '%s' of city '%s' must not be negative, got %g", attribute, name, weight)
+		}
+		if weight == 0 {
+			continue
+		}
+
+		total += weight
+		weighted = append(weighted, name)
+		cumulative = append(cumulative, total)
+	}
+
+	if len(weighted) == 0 {
+		return nil, nil, fmt.Errorf("no city has a positive '%s' attribute", attribute)
+	}
+
+	return weighted, cumulative, nil
+}
+
+// withinRadius returns the names of the city and the cities at most radius
+// roads away from it in sorted order, following roads in their direction only
+func (c *city) withinRadius(radius int) []string {
+	seen := map[string]bool{c.name: true}
+	names := []string{c.name}
+	level := []*city{c}
+	for distance := 0; distance < radius && len(level) > 0; distance++ {
+		var next []*city
+		for _, src := range level {
+			for _, d := range src.directions() {
+				neighbor := src.neighbors[d]
+				if !seen[neighbor.name] {
+					seen[neighbor.name] = true
+					names = append(names, neighbor.name)
+					next = append(next, neighbor)
+				}
+			}
+		}
+		level = next
+	}
+
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,219 @@
+package simulation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// placedCities returns the cities the aliens of sim landed in, by alien
+func placedCities(sim *Simulation) map[int]string {
+	placed := make(map[int]string, len(sim.aliens))
+	for _, a := range sim.aliens {
+		placed[a.name] = a.currentCity.name
+	}
+	return placed
+}
+
+// writePlacementFile writes a placement file to a temporary directory and
+// returns its path
+func writePlacementFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "placements.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestPlacementConfig_Validate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cfg           PlacementConfig
+		expectedError string
+	}{
+		{"default", PlacementConfig{}, ""},
+		{"uniform", PlacementConfig{Mode: PlacementUniform}, ""},
+		{"weighted", PlacementConfig{Mode: PlacementWeighted, Attribute: "population"}, ""},
+		{"weighted without attribute", PlacementConfig{Mode: PlacementWeighted}, "weighted placement requires a city attribute"},
+		{"clustered", PlacementConfig{Mode: PlacementClustered, Center: "A"}, ""},
+		{"clustered without center", PlacementConfig{Mode: PlacementClustered}, "clustered placement requires a center city"},
+		{"negative radius", PlacementConfig{Mode: PlacementClustered, Center: "A", Radius: -1}, "radius must not be negative, got -1"},
+		{"unknown mode", PlacementConfig{Mode: "everywhere"}, "unknown placement mode: everywhere"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cfg.Validate()
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestNewSimulation_withPlacement(t *testing.T) {
+	chain := strings.Replace(chainMap, "%s", "", 1)
+
+	t.Run("unique", func(t *testing.T) {
+		for seed := int64(0); seed < 20; seed++ {
+			sim, err := NewSimulation(strings.NewReader(chain), 4, WithSeed(seed), WithPlacement(PlacementConfig{Mode: PlacementUnique}))
+			require.NoError(t, err)
+			assert.ElementsMatch(t, []string{"A", "B", "C", "D"}, toSlice(placedCities(sim)))
+		}
+
+		_, err := NewSimulation(strings.NewReader(chain), 5, WithPlacement(PlacementConfig{Mode: PlacementUnique}))
+		assert.EqualError(t, err, "cannot place 5 aliens in 4 cities without aliens")
+	})
+
+	t.Run("unique with placed aliens", func(t *testing.T) {
+		input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "B"}, {"alien": 2, "city": "B"}`, 1)
+		sim, err := NewSimulation(strings.NewReader(input), 4, WithPlacement(PlacementConfig{Mode: PlacementUnique}))
+		require.NoError(t, err)
+		placed := placedCities(sim)
+		assert.Equal(t, "B", placed[1])
+		assert.Equal(t, "B", placed[2])
+		assert.NotEqual(t, placed[3], placed[4])
+		assert.NotContains(t, []string{placed[3], placed[4]}, "B", "expected no alien to land with the placed aliens")
+	})
+
+	t.Run("clustered", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for seed := int64(0); seed < 20; seed++ {
+			sim, err := NewSimulation(strings.NewReader(chain), 3, WithSeed(seed), WithPlacement(PlacementConfig{Mode: PlacementClustered, Center: "A", Radius: 1}))
+			require.NoError(t, err)
+			for _, c := range placedCities(sim) {
+				seen[c] = true
+			}
+		}
+		assert.Equal(t, map[string]bool{"A": true, "B": true}, seen)
+
+		_, err := NewSimulation(strings.NewReader(chain), 3, WithPlacement(PlacementConfig{Mode: PlacementClustered, Center: "E"}))
+		assert.EqualError(t, err, "unknown center city 'E'")
+	})
+
+	t.Run("explicit", func(t *testing.T) {
+		input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "B"}`, 1)
+		_, err := NewSimulation(strings.NewReader(input), 2, WithPlacement(PlacementConfig{Mode: PlacementExplicit}))
+		assert.EqualError(t, err, "alien 2 is not placed by the map or the placement file")
+
+		sim, err := NewSimulation(strings.NewReader(input), MapAliens, WithPlacement(PlacementConfig{Mode: PlacementExplicit}))
+		require.NoError(t, err)
+		assert.Equal(t, map[int]string{1: "B"}, placedCities(sim))
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := NewSimulation(strings.NewReader(chain), 2, WithPlacement(PlacementConfig{Mode: PlacementWeighted}))
+		assert.EqualError(t, err, "weighted placement requires a city attribute")
+	})
+}
+
+func TestNewSimulation_withWeightedPlacement(t *testing.T) {
+	input := `{
+  "cities": [
+    {"name": "A", "roads": [{"direction": "east", "city": "B"}], "attributes": {"population": 3}},
+    {"name": "B", "roads": [{"direction": "west", "city": "A"}, {"direction": "east", "city": "C"}], "attributes": {"population": 1}},
+    {"name": "C", "roads": [{"direction": "west", "city": "B"}], "attributes": {"population": 0, "name": "sea"}}
+  ]
+}`
+
+	weighted := func(attribute string) Option {
+		return WithPlacement(PlacementConfig{Mode: PlacementWeighted, Attribute: attribute})
+	}
+
+	counts := make(map[string]int)
+	sim, err := NewSimulation(strings.NewReader(input), 1000, WithSeed(1), weighted("population"))
+	require.NoError(t, err)
+	for _, c := range placedCities(sim) {
+		counts[c]++
+	}
+	assert.Zero(t, counts["C"], "expected no alien in a city without weight")
+	assert.InDelta(t, 750, counts["A"], 50)
+	assert.InDelta(t, 250, counts["B"], 50)
+
+	_, err = NewSimulation(strings.NewReader(input), 1, weighted("name"))
+	assert.EqualError(t, err, "attribute 'name' of city 'C' is not a number")
+
+	_, err = NewSimulation(strings.NewReader(input), 1, weighted("area"))
+	assert.EqualError(t, err, "no city has a positive 'area' attribute")
+
+	negative := strings.Replace(input, `"population": 1}`, `"population": -1}`, 1)
+	_, err = NewSimulation(strings.NewReader(negative), 1, weighted("population"))
+	assert.EqualError(t, err, "attribute 'population' of city 'B' must not be negative, got -1")
+}
+
+func TestNewSimulation_withPlacementFile(t *testing.T) {
+	input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "A"}`, 1)
+	path := writePlacementFile(t, `# aliens landing in the east
+alien 1 D
+alien 2 "C"
+`)
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens, WithPlacementFile(path))
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "D", 2: "C"}, placedCities(sim), "expected the placement file to replace the placements of the map")
+
+	_, err = NewSimulation(strings.NewReader(input), 1, WithPlacementFile(filepath.Join(t.TempDir(), "missing.txt")))
+	assert.ErrorContains(t, err, "failed to open placement file")
+}
+
+func TestValidateMap_withPlacementFile(t *testing.T) {
+	input := strings.Replace(chainMap, "%s", "", 1)
+	path := writePlacementFile(t, `alien 1 A
+alien two B
+place 3 C
+alien 4 "D
+alien 5
+alien 6 E
+`)
+
+	diagnostics, err := ValidateMap(strings.NewReader(input), WithPlacementFile(path))
+	assert.NoError(t, err, "expected no error when validating map")
+
+	actual := make([]string, len(diagnostics))
+	for i, d := range diagnostics {
+		actual[i] = strings.TrimPrefix(d.String(), path+":")
+	}
+	assert.Equal(t, []string{
+		"2:7: error: malformed placement 'two': the alien must be a number",
+		"3:1: error: malformed placement 'place 3 C', expected 'alien <n> <city>'",
+		`4:9: error: malformed city name '"D'`,
+		"5:1: error: malformed placement 'alien 5', expected 'alien <n> <city>'",
+		"6:1: error: invalid placement of alien 6 in city 'E': unknown city",
+	}, actual)
+}
+
+func TestCity_withinRadius(t *testing.T) {
+	sim := newChainSimulation(t, "A")
+
+	assert.Equal(t, []string{"B"}, sim.cities["B"].withinRadius(0))
+	assert.Equal(t, []string{"A", "B", "C"}, sim.cities["B"].withinRadius(1))
+	assert.Equal(t, []string{"A", "B", "C", "D"}, sim.cities["B"].withinRadius(2))
+	assert.Equal(t, []string{"A", "B", "C", "D"}, sim.cities["A"].withinRadius(10))
+}
+
+func TestSimulation_Step_landingBattle(t *testing.T) {
+	// aliens 1 and 2 land in B, alien 3 in D
+	sim := newChainSimulation(t, "B", "B", "D")
+
+	result, err := sim.Step()
+	require.NoError(t, err)
+	require.NotEmpty(t, result.Battles)
+	assert.Equal(t, Battle{City: "B", Aliens: []int{1, 2}, CityDestroyed: true}, result.Battles[0], "expected the aliens landing in B to fight before moving")
+	for _, m := range result.Moves {
+		assert.NotEqual(t, 1, m.Alien, "expected alien 1 to die before moving")
+		assert.NotEqual(t, 2, m.Alien, "expected alien 2 to die before moving")
+	}
+}
+
+// toSlice returns the values of m ordered by key
+func toSlice(m map[int]string) []string {
+	values := make([]string, 0, len(m))
+	for i := 1; i <= len(m); i++ {
+		values = append(values, m[i])
+	}
+	return values
+}
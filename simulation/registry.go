@@ -0,0 +1,70 @@
+package simulation
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// registry holds named values, e.g. vocabularies or movement strategies. It is
+// safe for concurrent use, kind names the values in errors
+type registry[T any] struct {
+	kind   string
+	mu     sync.RWMutex
+	values map[string]T
+}
+
+func newRegistry[T any](kind string) *registry[T] {
+	return &registry[T]{kind: kind, values: map[string]T{}}
+}
+
+// register adds value under name, names must not be empty and can only be
+// registered once
+func (r *registry[T]) register(name string, value T) error {
+	if name == "" {
+		return fmt.Errorf("%s name must not be empty", r.kind)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.values[name]; exists {
+		return fmt.Errorf("%s '%s' is already registered", r.kind, name)
+	}
+	r.values[name] = value
+
+	return nil
+}
+
+// unregister removes the value registered under name, it is used by tests to
+// clean up the values they register
+func (r *registry[T]) unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.values, name)
+}
+
+// names returns the names of all registered values in sorted order
+func (r *registry[T]) names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := maps.Keys(r.values)
+	slices.Sort(names)
+	return names
+}
+
+// lookup returns the value registered under name
+func (r *registry[T]) lookup(name string) (T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	value, ok := r.values[name]
+	if !ok {
+		return value, fmt.Errorf("unknown %s '%s'", r.kind, name)
+	}
+	return value, nil
+}
@@ -0,0 +1,26 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := newRegistry[int]("number")
+
+	assert.NoError(t, r.register("two", 2))
+	assert.NoError(t, r.register("one", 1))
+	assert.EqualError(t, r.register("one", 3), "number 'one' is already registered")
+	assert.EqualError(t, r.register("", 0), "number name must not be empty")
+	assert.Equal(t, []string{"one", "two"}, r.names())
+
+	n, err := r.lookup("two")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	r.unregister("two")
+	_, err = r.lookup("two")
+	assert.EqualError(t, err, "unknown number 'two'")
+	assert.Equal(t, []string{"one"}, r.names())
+}
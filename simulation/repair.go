@@ -0,0 +1,115 @@
+package simulation
+
+import (
+	"fmt"
+	"io"
+)
+
+// RepairAction is the kind of change made to a map when repairing it
+type RepairAction string
+
+// repair actions
+const (
+	// the missing reverse road of a road was added
+	RoadAdded RepairAction = "added"
+	// a road was dropped because its reverse direction already leads to
+	// another city
+	RoadDropped RepairAction = "dropped"
+)
+
+// RoadRepair is a change made to a map to make its roads symmetric. City,
+// Direction and Neighbor describe the road that was added or dropped
+type RoadRepair struct {
+	Action RepairAction
+	// File, Line and Column are the position of the road that was dropped,
+	// or of the road an added road is the reverse of
+	File      string
+	Line      int
+	Column    int
+	City      string
+	Direction string
+	Neighbor  string
+	// ReverseDirection is the opposite of Direction, Conflict is the city the
+	// reverse direction of a dropped road leads to
+	ReverseDirection string
+	Conflict         string
+}
+
+// String returns the repair in the format "line:column: repair: message",
+// prefixed with "file:" if the file is known
+func (r RoadRepair) String() string {
+	if r.Action == RoadDropped {
+		return fmt.Sprintf(
+			"%s%d:%d: repair: dropped road '%s=%s' from city '%s', road '%s' of city '%s' leads to '%s'",
+			filePrefix(r.File), r.Line, r.Column, r.Direction, r.Neighbor, r.City,
+			r.ReverseDirection, r.Neighbor, r.Conflict,
+		)
+	}
+
+	return fmt.Sprintf(
+		"%s%d:%d: repair: added road '%s=%s' to city '%s'",
+		filePrefix(r.File), r.Line, r.Column, r.Direction, r.Neighbor, r.City,
+	)
+}
+
+// repairRoad makes the road in direction d from city c symmetric. The
+// missing reverse road is added to the neighbor, or the road is dropped if
+// the reverse direction of the neighbor already leads to another city
+func repairRoad(c *city, d direction, pos roadPosition) RoadRepair {
+	n := c.neighbors[d]
+	repair := RoadRepair{File: pos.file, Line: pos.line, Column: pos.column}
+
+	reverse := c.opposite(d)
+
+	if conflict, ok := n.neighbors[reverse]; ok {
+		delete(c.neighbors, d)
+		repair.Action = RoadDropped
+		repair.City = c.name
+		repair.Direction = string(d)
+		repair.Neighbor = n.name
+		repair.ReverseDirection = string(reverse)
+		repair.Conflict = conflict.name
+		return repair
+	}
+
+	n.neighbors[reverse] = c
+	repair.Action = RoadAdded
+	repair.City = n.name
+	repair.Direction = string(reverse)
+	repair.Neighbor = c.name
+	repair.ReverseDirection = string(d)
+	return repair
+}
+
+// RepairMap parses the map from input and makes its roads symmetric. The
+// changes made are returned along with the problems that could not be
+// repaired. If output is not nil and no errors remain, the repaired map is
+// written to it in canonical form: cities and roads sorted by name and
+// direction. Only the options affecting how the map is parsed are used, as
+// for ValidateMap. The returned error is only set if the input cannot be
+// read, the output cannot be written or the options are invalid
+func RepairMap(input io.Reader, output io.Writer, opts ...Option) ([]RoadRepair, []Diagnostic, error) {
+	cfg := parseOptions(opts)
+	cfg.repair = true
+	m, err := parseMap(input, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if output == nil {
+		return m.repairs, m.diagnostics, nil
+	}
+
+	for _, d := range m.diagnostics {
+		if d.Severity == SeverityError {
+			return m.repairs, m.diagnostics, nil
+		}
+	}
+
+	sim := &Simulation{cities: m.cities}
+	if _, err := io.WriteString(output, sim.CitiesToString(m.cities)); err != nil {
+		return nil, nil, fmt.Errorf("failed to write map: %w", err)
+	}
+
+	return m.repairs, m.diagnostics, nil
+}
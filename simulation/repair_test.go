@@ -0,0 +1,69 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepairMap(t *testing.T) {
+	input := `Foo north=Bar west=Baz
+Bar east=Qux
+Baz south=Bar
+Qux west=Bar north=Baz
+Zox north=Zox`
+
+	output := &strings.Builder{}
+	repairs, diagnostics, err := RepairMap(strings.NewReader(input), output)
+	assert.NoError(t, err, "expected no error when repairing map")
+	assert.Len(t, diagnostics, 1, "expected only the self road warning after repairing")
+
+	actual := make([]string, len(repairs))
+	for i, r := range repairs {
+		actual[i] = r.String()
+	}
+	assert.Equal(t, []string{
+		"1:5: repair: added road 'south=Foo' to city 'Bar'",
+		"1:15: repair: added road 'east=Foo' to city 'Baz'",
+		"3:5: repair: added road 'north=Baz' to city 'Bar'",
+		"4:14: repair: dropped road 'north=Baz' from city 'Qux', road 'south' of city 'Baz' leads to 'Bar'",
+		"5:5: repair: added road 'south=Zox' to city 'Zox'",
+	}, actual)
+
+	assert.Equal(t, `Bar east=Qux north=Baz south=Foo
+Baz east=Foo south=Bar
+Foo north=Bar west=Baz
+Qux west=Bar
+Zox north=Zox south=Zox
+`, output.String())
+
+	// the repaired map is valid without repairing
+	_, err = parseInput(strings.NewReader(output.String()), parseConfig{})
+	assert.NoError(t, err, "expected repaired map to be valid")
+}
+
+func TestRepairMap_unrepairable(t *testing.T) {
+	output := &strings.Builder{}
+	repairs, diagnostics, err := RepairMap(strings.NewReader("Foo north=Bar\nBar up=Foo\nBaz east=Foo"), output)
+	assert.NoError(t, err)
+	assert.Equal(t, []RoadRepair{
+		{Action: RoadAdded, Line: 3, Column: 5, City: "Foo", Direction: "west", Neighbor: "Baz", ReverseDirection: "east"},
+	}, repairs)
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, InvalidDirection, diagnostics[0].Err.Kind)
+	}
+	assert.Empty(t, output.String(), "expected no map to be written when errors remain")
+}
+
+func TestNewSimulation_withRepair(t *testing.T) {
+	_, err := NewSimulation(strings.NewReader("Foo north=Bar\nBar"), 2)
+	assert.Error(t, err, "expected asymmetric map to be rejected without repair")
+
+	sim, err := NewSimulation(strings.NewReader("Foo north=Bar\nBar"), 2, WithRepair(), WithSeed(1))
+	assert.NoError(t, err)
+	assert.Equal(t, []RoadRepair{
+		{Action: RoadAdded, Line: 1, Column: 5, City: "Bar", Direction: "south", Neighbor: "Foo", ReverseDirection: "north"},
+	}, sim.Repairs())
+	assert.Equal(t, "Bar south=Foo\nFoo north=Bar\n", sim.CitiesToString(sim.SurvivedCities()))
+}
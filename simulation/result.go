@@ -0,0 +1,133 @@
+package simulation
+
+import "golang.org/x/exp/slices"
+
+// AlienFate describes what happened to an alien
+type AlienFate string
+
+// alien fates
+const (
+	// the alien is alive and able to move
+	FateAlive AlienFate = "alive"
+	// the alien was killed in a battle
+	FateDead AlienFate = "dead"
+	// the alien is alive but stuck in a city without roads
+	FateTrapped AlienFate = "trapped"
+)
+
+// Result is a report of the simulation, it can be requested at any time and
+// reflects the world after the last iteration
+type Result struct {
+	Seed       int64    `json:"seed"`
+	Iterations int      `json:"iterations"`
+	State      SimState `json:"state"`
+	// SurvivingCities contains the cities that are not destroyed with their
+	// remaining roads, ordered by city name
+	SurvivingCities []SurvivingCity `json:"surviving_cities"`
+	// DestroyedCities contains the destroyed cities in the order they fell
+	DestroyedCities []DestroyedCity `json:"destroyed_cities"`
+	// Aliens contains the history of each alien, ordered by alien name
+	Aliens []AlienResult `json:"aliens"`
+}
+
+// AlienResult is the history of a single alien
+type AlienResult struct {
+	Alien int `json:"alien"`
+	// Moves is the number of times the alien moved to another city
+	Moves int `json:"moves"`
+	// Visited contains the cities the alien has been in, in order, starting
+	// with the city it was placed in
+	Visited []string  `json:"visited"`
+	Fate    AlienFate `json:"fate"`
+	// FateIteration and FateCity are the iteration and the city in which the
+	// alien died or became trapped, they are empty for alive aliens. Aliens
+	// killed on a road died in the city they came from
+	FateIteration int    `json:"fate_iteration,omitempty"`
+	FateCity      string `json:"fate_city,omitempty"`
+}
+
+// SurvivingCity describes a city that is not destroyed
+type SurvivingCity struct {
+	City string `json:"city"`
+	// Roads contains the remaining roads of the city, ordered by direction
+	Roads []Road `json:"roads"`
+}
+
+// Road is a road from a city in a direction to another city
+type Road struct {
+	Direction string `json:"direction"`
+	City      string `json:"city"`
+	// OneWay is set if the road can't be travelled back
+	OneWay bool `json:"one_way,omitempty"`
+}
+
+// DestroyedCity describes a city destroyed in a battle
+type DestroyedCity struct {
+	City      string `json:"city"`
+	Iteration int    `json:"iteration"`
+	// Aliens contains the aliens that destroyed the city
+	Aliens []int `json:"aliens"`
+}
+
+// Result returns a report of the simulation
+func (s *Simulation) Result() Result {
+	state := s.state
+	if state == "" {
+		state = SimStateRunning
+	}
+
+	result := Result{
+		Seed:       s.seed,
+		Iterations: s.iteration,
+		State:      state,
+		Aliens:     make([]AlienResult, len(s.aliens)),
+		// keep the lists non-nil so they are encoded as empty lists
+		SurvivingCities: []SurvivingCity{},
+		DestroyedCities: []DestroyedCity{},
+	}
+
+	for i, a := range s.aliens {
+		alienResult := AlienResult{
+			Alien:   a.name,
+			Moves:   a.moves,
+			Visited: append([]string(nil), a.visited...),
+			Fate:    FateAlive,
+		}
+
+		switch {
+		case a.isDead():
+			alienResult.Fate = FateDead
+		case a.isTrapped():
+			alienResult.Fate = FateTrapped
+		}
+
+		if alienResult.Fate != FateAlive {
+			alienResult.FateIteration = a.fateIteration
+			alienResult.FateCity = a.fateCity
+		}
+
+		result.Aliens[i] = alienResult
+	}
+
+	for _, cityName := range s.sortedCityNames() {
+		c := s.cities[cityName]
+		if !c.isDestroyed() {
+			result.SurvivingCities = append(result.SurvivingCities, SurvivingCity{City: c.name, Roads: c.roads()})
+			continue
+		}
+
+		result.DestroyedCities = append(result.DestroyedCities, DestroyedCity{
+			City:      c.name,
+			Iteration: c.destroyedAt,
+			Aliens:    append([]int(nil), c.destroyedBy...),
+		})
+	}
+
+	// cities fell in iteration order, cities destroyed in the same iteration
+	// are kept in name order
+	slices.SortStableFunc(result.DestroyedCities, func(a, b DestroyedCity) bool {
+		return a.Iteration < b.Iteration
+	})
+
+	return result
+}
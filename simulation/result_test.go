@@ -0,0 +1,81 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulation_Result(t *testing.T) {
+	sim := newSettledSimulation(t, "City1 north=City2\nCity2 south=City1 east=City3\nCity3 west=City2",
+		[]string{"City2", "City1"},
+		[]string{"City3", "City2"},
+		[]string{"City2"},
+		[]string{"City3"},
+	)
+
+	state, err := sim.Run()
+	assert.NoError(t, err, "expected no error when running simulation")
+
+	assert.Equal(t, Result{
+		Seed:       1,
+		Iterations: 1,
+		State:      state,
+		Aliens: []AlienResult{
+			{Alien: 1, Moves: 1, Visited: []string{"City2", "City1"}, Fate: FateTrapped, FateIteration: 1, FateCity: "City1"},
+			{Alien: 2, Moves: 1, Visited: []string{"City3", "City2"}, Fate: FateDead, FateIteration: 1, FateCity: "City2"},
+			{Alien: 3, Moves: 1, Visited: []string{"City2"}, Fate: FateDead, FateIteration: 1, FateCity: "City2"},
+			{Alien: 4, Moves: 1, Visited: []string{"City3"}, Fate: FateTrapped, FateIteration: 1, FateCity: "City3"},
+		},
+		SurvivingCities: []SurvivingCity{
+			{City: "City1", Roads: []Road{}},
+			{City: "City3", Roads: []Road{}},
+		},
+		DestroyedCities: []DestroyedCity{
+			{City: "City2", Iteration: 1, Aliens: []int{2, 3}},
+		},
+	}, sim.Result())
+}
+
+func TestSimulation_Result_history(t *testing.T) {
+	input := `Fex east=Bar west=Jaz
+Bar west=Fex south=Zor east=Qux
+Zor north=Bar west=Baz
+Baz south=Jaz north=Qux east=Zor
+Jaz north=Baz east=Fex
+Qux north=Zox south=Baz west=Bar
+Zox south=Qux east=Xaz
+Xaz west=Zox north=Vex
+Vex south=Xaz`
+
+	sim, err := NewSimulation(strings.NewReader(input), 6, WithSeed(3))
+	assert.NoError(t, err, "expected no error when creating simulation")
+
+	var destroyed []CityDestroyed
+	sim.Subscribe(ObserverFunc(func(e Event) {
+		if e, ok := e.(CityDestroyed); ok {
+			destroyed = append(destroyed, e)
+		}
+	}))
+
+	state, err := sim.Run()
+	assert.NoError(t, err, "expected no error when running simulation")
+
+	result := sim.Result()
+	assert.Equal(t, state, result.State, "expected the result to contain the end state")
+	assert.Equal(t, sim.iteration, result.Iterations, "expected the result to contain the number of iterations")
+	assert.Len(t, result.Aliens, 6, "expected a result for each alien")
+	assert.Len(t, result.DestroyedCities, len(destroyed), "expected a result for each destroyed city")
+
+	for i, d := range destroyed {
+		assert.Equal(t, DestroyedCity{City: d.City, Iteration: d.Iteration, Aliens: d.Aliens}, result.DestroyedCities[i])
+	}
+
+	for _, a := range result.Aliens {
+		assert.Len(t, a.Visited, a.Moves+1, "expected each move to be recorded in the visited cities")
+		if a.Fate == FateDead {
+			assert.Equal(t, a.Visited[len(a.Visited)-1], a.FateCity, "expected a dead alien to die in the last city it visited")
+		}
+	}
+}
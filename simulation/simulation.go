@@ -1,28 +1,37 @@
 package simulation
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"math/rand"
 	"strings"
+	"time"
 
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
 )
 
-type simState string
+// SimState is the state of a simulation
+type SimState string
 
 // simulation states
 const (
 	// simulation is still running
-	SimStateRunning simState = "STATE_RUNNING"
+	SimStateRunning SimState = "STATE_RUNNING"
 	// all aliens are dead or trapped
-	SimStateAllAliensDeadOrTrapped = "STATE_ALL_ALIENS_DEAD_OR_TRAPPED"
+	SimStateAllAliensDeadOrTrapped SimState = "STATE_ALL_ALIENS_DEAD_OR_TRAPPED"
 	// only one alien left (cannot do battles)
-	SimStateOnlyOneAlienLeft = "STATE_ONLY_ONE_ALIEN_LEFT"
+	SimStateOnlyOneAlienLeft SimState = "STATE_ONLY_ONE_ALIEN_LEFT"
 	// all alive aliens cannot reach each other
-	SimStateAliveAliensDisconnected = "STATE_ALIVE_ALIENS_DISCONNECTED"
+	SimStateAliveAliensDisconnected SimState = "STATE_ALIVE_ALIENS_DISCONNECTED"
+	// more than one alien is left, but fewer than needed for a battle
+	SimStateTooFewAliensLeft SimState = "STATE_TOO_FEW_ALIENS_LEFT"
+	// the iteration cap or the per-alien move cap has been reached
+	SimStateMaxIterationsReached SimState = "STATE_MAX_ITERATIONS_REACHED"
+	// the context passed to RunContext was cancelled or its deadline passed
+	SimStateInterrupted SimState = "STATE_INTERRUPTED"
 	// all cities are destroyed (cannot reach this state)
 	// SimStateAllCitiesDestroyed
 )
@@ -30,80 +39,408 @@ const (
 type Simulation struct {
 	// cities contains all cities and their neighbors
 	cities map[string]*city
+	// cityNames contains the names of all cities in sorted order, it is used
+	// to traverse the cities deterministically
+	cityNames []string
 	// aliens contains all aliens in the world
 	aliens    []*alien
 	iteration int
+	// maxIterations and maxMoves limit how long the simulation runs, a value
+	// of 0 or less means no limit
+	maxIterations int
+	maxMoves      int
+	// rnd is the only source of randomness in the simulation, seed is the
+	// value it was seeded with
+	rnd  *rand.Rand
+	seed int64
+	// state is the state after the last iteration
+	state     SimState
+	observers []Observer
+	// logger is used for diagnostics, slog.Default is used when nil
+	logger *slog.Logger
+	// parse configures how the map is parsed, repairs are the changes made
+	// to the map when repairing it
+	parse    parseConfig
+	repairs  []RoadRepair
+	metadata Metadata
+	// initialRoads contains the roads of each city before the first
+	// iteration, see WriteDOT
+	initialRoads map[string][]Road
+	// movement is the name of the movement strategy of all aliens,
+	// alienMovement overrides it for single aliens
+	movement      string
+	alienMovement map[int]string
+	// battleRule is the name of the battle rule, rule is the rule itself.
+	// minCombatants is the number of aliens in a city needed for a battle.
+	// The defaults are used when rule is nil or minCombatants is 0
+	battleRule    string
+	rule          BattleRule
+	minCombatants int
+	// strengths contains the strengths set for single aliens
+	strengths map[int]float64
+	// placement configures how aliens not placed by the map are placed
+	placement PlacementConfig
+	// meetable is set once enough alive aliens have been found able to
+	// meet, it is reset by steps that may change what they can reach
+	meetable bool
+	// simultaneous is set if all aliens pick their move before any alien
+	// moves, crossing decides what happens to aliens crossing each other on
+	// a road then
+	simultaneous bool
+	crossing     CrossingOutcome
 }
 
+// Move describes an alien moving from one city to another
+type Move struct {
+	Alien int
+	From  string
+	To    string
+}
+
+// Battle describes a battle in a city, all aliens in the battle except the
+// survivors are killed
+type Battle struct {
+	City string
+	// Aliens contains all aliens in the battle, Survivors the aliens that
+	// survived it
+	Aliens        []int
+	Survivors     []int
+	CityDestroyed bool
+}
+
+// Placement places an alien in a city when the simulation is created
+type Placement struct {
+	Alien int    `json:"alien"`
+	City  string `json:"city"`
+}
+
+// StepResult describes what happened during one iteration of the simulation
+type StepResult struct {
+	Iteration int
+	Moves     []Move
+	// Crossings contains the crossings on roads with simultaneous moves,
+	// they are resolved before the aliens arrive in the cities
+	Crossings []Crossing
+	Battles   []Battle
+	// State is the state of the simulation after the iteration
+	State SimState
+}
+
+// MapAliens can be passed to NewSimulation as number of aliens to use the
+// number of aliens set in the header of the map. If the map sets no number
+// but places aliens, only the placed aliens are created
+const MapAliens = -1
+
 // NewSimulation creates a new simulation from the input string and number of
-// aliens to randomly place in the world
-func NewSimulation(input io.Reader, nrOfAliens int) (*Simulation, error) {
-	cities, err := parseInput(input)
+// aliens to randomly place in the world, see MapAliens to use the number set
+// in the map. Aliens placed by the map start in their city instead. The map
+// is read in the line format or as a JSON map, see WriteMapJSON. By default
+// each alien is allowed to make DefaultMaxMoves moves with the
+// DefaultMovementStrategy, the number of iterations is not capped and the
+// random source is seeded with the current time
+func NewSimulation(input io.Reader, nrOfAliens int, opts ...Option) (*Simulation, error) {
+	sim := &Simulation{maxMoves: DefaultMaxMoves, minCombatants: DefaultMinCombatants}
+	WithSeed(time.Now().UnixNano())(sim)
+	for _, opt := range opts {
+		opt(sim)
+	}
+
+	m, err := parseInput(input, sim.parse)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse input: %w", err)
 	}
+	cities := m.cities
+	sim.cities = cities
+	sim.repairs = m.repairs
+	sim.metadata = m.metadata
+
+	// - create aliens, a map with placements but without a number of aliens
+	// creates only the placed aliens
+	var aliens []*alien
+	placed := make(map[int]string, len(m.placements))
+	for _, p := range m.placements {
+		placed[p.Alien] = p.City
+	}
+	switch {
+	case nrOfAliens == MapAliens && m.metadata.Aliens == 0 && len(m.placements) > 0:
+		for _, p := range m.placements {
+			aliens = append(aliens, &alien{name: p.Alien})
+		}
+		slices.SortFunc(aliens, func(a, b *alien) bool { return a.name < b.name })
+	case nrOfAliens == MapAliens && m.metadata.Aliens == 0:
+		return nil, fmt.Errorf("no number of aliens given and the map has no @%s directive", directiveAliens)
+	default:
+		if nrOfAliens == MapAliens {
+			nrOfAliens = m.metadata.Aliens
+		}
+		for _, p := range m.placements {
+			if p.Alien > nrOfAliens {
+				return nil, fmt.Errorf("alien %d is placed in city '%s', but there are only %d aliens", p.Alien, p.City, nrOfAliens)
+			}
+		}
+		aliens = make([]*alien, nrOfAliens)
+		for i := 0; i < nrOfAliens; i++ {
+			aliens[i] = &alien{name: i + 1}
+		}
+	}
 
-	// - create aliens
-	aliens := make([]*alien, nrOfAliens)
-	for i := 0; i < nrOfAliens; i++ {
-		aliens[i] = &alien{name: i + 1}
+	if len(aliens) > 0 && len(cities) == 0 {
+		return nil, fmt.Errorf("no cities to place %d aliens in", len(aliens))
 	}
 
-	cityKeys := make([]string, len(cities))
-	i := 0
-	for cityName := range cities {
-		cityKeys[i] = cityName
-		i++
+	// place aliens in the cities set by the map, and the others according to
+	// the placement config
+	if err := sim.placement.Validate(); err != nil {
+		return nil, err
+	}
+	if err := sim.placeAliens(aliens, placed); err != nil {
+		return nil, err
 	}
 
-	// randomly place aliens in cities
-	for _, alien := range aliens {
-		// get random city
-		rndCityIndex := rand.Intn(len(cityKeys))
-		cities[cityKeys[rndCityIndex]].visitingAliens = append(
-			cities[cityKeys[rndCityIndex]].visitingAliens,
-			alien,
-		)
-		alien.currentCity = cities[cityKeys[rndCityIndex]]
+	sim.aliens = aliens
+
+	if sim.crossing != "" && !slices.Contains(CrossingOutcomes(), string(sim.crossing)) {
+		return nil, fmt.Errorf("unknown crossing outcome: %s", sim.crossing)
+	}
+	if err := sim.configureAliens(); err != nil {
+		return nil, err
 	}
 
-	return &Simulation{cities: cities, aliens: aliens}, nil
+	sim.initialRoads = make(map[string][]Road, len(cities))
+	for name, c := range cities {
+		sim.initialRoads[name] = c.roads()
+	}
+
+	return sim, nil
+}
+
+// configureAliens sets the movement strategy and the strength of each alien,
+// and the battle rule of the simulation
+func (s *Simulation) configureAliens() error {
+	if s.minCombatants < 2 {
+		return fmt.Errorf("the minimum number of combatants must be at least 2, got %d", s.minCombatants)
+	}
+
+	var err error
+	if s.rule, err = lookupBattleRule(s.battleRule); err != nil {
+		return err
+	}
+
+	strategy, err := lookupMovementStrategy(s.movement)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[int]*alien, len(s.aliens))
+	for _, a := range s.aliens {
+		a.strategy = strategy
+		a.strength = DefaultStrength
+		byName[a.name] = a
+	}
+
+	names := maps.Keys(s.alienMovement)
+	slices.Sort(names)
+	for _, name := range names {
+		movement := s.alienMovement[name]
+		a, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("movement strategy '%s' set for alien %d, but there is no such alien", movement, name)
+		}
+		if a.strategy, err = lookupMovementStrategy(movement); err != nil {
+			return fmt.Errorf("alien %d: %w", name, err)
+		}
+	}
+
+	names = maps.Keys(s.strengths)
+	slices.Sort(names)
+	for _, name := range names {
+		strength := s.strengths[name]
+		a, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("strength %g set for alien %d, but there is no such alien", strength, name)
+		}
+		if strength <= 0 {
+			return fmt.Errorf("strength of alien %d must be greater than 0, got %g", name, strength)
+		}
+		a.strength = strength
+	}
+
+	return nil
+}
+
+// fightBattles simulates the battles in all cities with enough aliens, in
+// city name order, and adds them to the result
+func (s *Simulation) fightBattles(result *StepResult) error {
+	for _, cityName := range s.sortedCityNames() {
+		c := s.cities[cityName]
+		battle, fallen, err := c.battle(s.battleRules())
+		if err != nil {
+			return fmt.Errorf("failed to simulate battle: %w", err)
+		}
+		if battle == nil {
+			continue
+		}
+
+		for _, a := range fallen {
+			a.fateIteration = s.iteration
+			a.fateCity = cityName
+		}
+		result.Battles = append(result.Battles, *battle)
+		s.emit(BattleFought{
+			Iteration:     s.iteration,
+			City:          battle.City,
+			Aliens:        battle.Aliens,
+			Survivors:     battle.Survivors,
+			CityDestroyed: battle.CityDestroyed,
+		})
+
+		if battle.CityDestroyed {
+			c.destroyedAt = s.iteration
+			c.destroyedBy = battle.Aliens
+			s.emit(CityDestroyed{Iteration: s.iteration, City: battle.City, Aliens: battle.Aliens})
+		}
+	}
+
+	return nil
+}
+
+// battleRules returns the battle rule, the minimum number of combatants and
+// the random source for battles
+func (s *Simulation) battleRules() (BattleRule, int, *rand.Rand) {
+	rule, min := s.rule, s.minCombatants
+	if rule == nil {
+		rule = BattleFunc(destroyAllBattle)
+	}
+	if min == 0 {
+		min = DefaultMinCombatants
+	}
+	return rule, min, s.rnd
 }
 
 // Run runs the simulation until it ends
-func (s *Simulation) Run() (simState, error) {
+func (s *Simulation) Run() (SimState, error) {
+	return s.RunContext(context.Background())
+}
+
+// RunContext runs the simulation until it ends or until ctx is done. The
+// context is checked between iterations, if it is done SimStateInterrupted is
+// returned and the world is left as it was after the last full iteration, so
+// SurvivedCities and Result can still be used to get the partial result
+func (s *Simulation) RunContext(ctx context.Context) (SimState, error) {
 	for {
-		s.iteration++
+		if ctx.Err() != nil {
+			s.log().Warn("simulation interrupted", "iteration", s.iteration, "err", ctx.Err())
+			s.state = SimStateInterrupted
+			s.emit(SimulationEnded{Iterations: s.iteration, State: s.state})
+			return s.state, nil
+		}
 
-		log.Printf("iteration %d", s.iteration)
+		result, err := s.Step()
+		if err != nil {
+			return result.State, err
+		}
 
-		// move aliens
-		for _, alien := range s.aliens {
-			alien.move()
+		if result.State != SimStateRunning {
+			return result.State, nil
 		}
+	}
+}
 
-		// simulate battles
-		for _, city := range s.cities {
-			err := city.battle()
-			if err != nil {
-				return SimStateRunning, fmt.Errorf("failed to simulate battle: %w", err)
-			}
+// Step runs exactly one iteration of the simulation: aliens move, battles are
+// fought and the end state is checked. Aliens move one after another in
+// order, or all at once with WithSimultaneousMoves. Once the simulation has
+// ended, Step doesn't change the world anymore and returns the end state
+func (s *Simulation) Step() (StepResult, error) {
+	if s.state != "" && s.state != SimStateRunning {
+		return StepResult{Iteration: s.iteration, State: s.state}, nil
+	}
+
+	s.iteration++
+
+	s.log().Debug("iteration started", "iteration", s.iteration)
+
+	result := StepResult{Iteration: s.iteration, State: SimStateRunning}
+
+	// aliens that landed in the same city fight before anyone moves
+	if s.iteration == 1 {
+		if err := s.fightBattles(&result); err != nil {
+			return result, err
+		}
+	}
+
+	// move aliens, aliens that have used up their moves stay where they are
+	move := s.moveInOrder
+	if s.simultaneous {
+		move = s.moveSimultaneously
+	}
+	if err := move(&result); err != nil {
+		return result, err
+	}
+
+	// simulate battles
+	if err := s.fightBattles(&result); err != nil {
+		return result, err
+	}
+
+	if s.mayChangeReach(result) {
+		s.meetable = false
+	}
+
+	// report aliens that became trapped during this iteration
+	for _, alien := range s.aliens {
+		if !alien.trapped && alien.isTrapped() {
+			alien.trapped = true
+			alien.fateIteration = s.iteration
+			alien.fateCity = alien.currentCity.name
+			s.emit(AlienTrapped{Iteration: s.iteration, Alien: alien.name, City: alien.currentCity.name})
+		}
+	}
+
+	s.state = s.checkEndState()
+	result.State = s.state
+
+	s.emit(IterationCompleted{Iteration: s.iteration, State: s.state})
+	if s.state != SimStateRunning {
+		s.emit(SimulationEnded{Iterations: s.iteration, State: s.state})
+	}
+
+	return result, nil
+}
+
+// moveInOrder lets the aliens move one after another in order, so each alien
+// sees the moves of the aliens before it
+func (s *Simulation) moveInOrder(result *StepResult) error {
+	for _, alien := range s.aliens {
+		if !alien.hasMovesLeft(s.maxMoves) {
+			continue
 		}
 
-		simState := s.checkEndState()
-		if simState != SimStateRunning {
-			return simState, nil
+		from := alien.currentCity
+		moved, err := alien.move(s.cities, s.rnd)
+		if err != nil {
+			return fmt.Errorf("failed to move alien: %w", err)
+		}
+		if moved {
+			move := Move{
+				Alien: alien.name,
+				From:  from.name,
+				To:    alien.currentCity.name,
+			}
+			result.Moves = append(result.Moves, move)
+			s.emit(AlienMoved{Iteration: s.iteration, Alien: move.Alien, From: move.From, To: move.To})
 		}
 	}
+
+	return nil
 }
 
-func (s *Simulation) checkEndState() simState {
-	var state simState
+func (s *Simulation) checkEndState() SimState {
+	var state SimState
 
 	// check if all aliens are dead or trapped
 	state = SimStateAllAliensDeadOrTrapped
 	for _, alien := range s.aliens {
-		log.Printf("alien %d is dead: %t, is trapped: %t", alien.name, alien.isDead(), alien.isTrapped())
+		s.log().Debug("alien status", "alien", alien.name, "dead", alien.isDead(), "trapped", alien.isTrapped())
 		if !alien.isDead() && !alien.isTrapped() {
 			state = SimStateRunning
 			break
@@ -114,40 +451,35 @@ func (s *Simulation) checkEndState() simState {
 		return state
 	}
 
-	// check if only one alien left
-	state = SimStateOnlyOneAlienLeft
+	// check if only one alien left, or too few for a battle
 	aliveAliens := make([]*alien, 0)
 	for _, alien := range s.aliens {
 		if !alien.isDead() {
 			aliveAliens = append(aliveAliens, alien)
-			log.Printf("alien %d is alive", alien.name)
-		} else {
-			log.Printf("alien %d is dead", alien.name)
 		}
 	}
-	if len(aliveAliens) > 1 {
-		state = SimStateRunning
+	if len(aliveAliens) == 1 {
+		return SimStateOnlyOneAlienLeft
 	}
-
-	if state != SimStateRunning {
-		return state
+	_, minCombatants, _ := s.battleRules()
+	if len(aliveAliens) < minCombatants {
+		return SimStateTooFewAliensLeft
 	}
 
-	// check if alive aliens are able to reach each other
-	state = SimStateAliveAliensDisconnected
-	for _, alien := range aliveAliens {
-		for _, target := range aliveAliens {
-			if alien == target {
-				continue
-			}
-			visited := make(map[string]bool)
-			log.Printf("alien %d in city %s is able to reach alien %d in city %s", alien.name, alien.currentCity.name, target.name, target.currentCity.name)
-			if alien.currentCity.reachableFrom(target.currentCity, visited) {
-				state = SimStateRunning
-				return state
-			}
+	// check if enough alive aliens are able to reach each other for a
+	// battle, the result is kept until a step may change what they can reach
+	if !s.meetable {
+		s.log().Debug("checking if aliens can meet", "aliens", len(aliveAliens))
+		cities := make([]*city, len(aliveAliens))
+		for i, alien := range aliveAliens {
+			cities[i] = alien.currentCity
 		}
+		s.meetable = canMeet(cities, minCombatants)
+	}
+	if s.meetable {
+		return s.checkLimits()
 	}
+	state = SimStateAliveAliensDisconnected
 
 	// check if all cities are destroyed
 	// state = SimStateAllCitiesDestroyed
@@ -165,6 +497,92 @@ func (s *Simulation) checkEndState() simState {
 	return state
 }
 
+// mayChangeReach returns true if the step may have changed what the alive
+// aliens can reach: a battle was fought, an alien died on a road, or an alien
+// moved to a city without a road back. Moving along a road with a road back
+// keeps the cities an alien can reach
+func (s *Simulation) mayChangeReach(result StepResult) bool {
+	if len(result.Battles) > 0 {
+		return true
+	}
+
+	for _, crossing := range result.Crossings {
+		if len(crossing.Survivors) < len(crossing.Aliens) {
+			return true
+		}
+	}
+
+	for _, move := range result.Moves {
+		from := s.cities[move.From]
+		roadBack := false
+		for _, neighbor := range s.cities[move.To].neighbors {
+			if neighbor == from {
+				roadBack = true
+				break
+			}
+		}
+		if !roadBack {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortedCityNames returns the names of all cities in sorted order
+func (s *Simulation) sortedCityNames() []string {
+	if len(s.cityNames) != len(s.cities) {
+		s.cityNames = maps.Keys(s.cities)
+		slices.Sort(s.cityNames)
+	}
+	return s.cityNames
+}
+
+// log returns the logger used for diagnostics
+func (s *Simulation) log() *slog.Logger {
+	if s.logger == nil {
+		return slog.Default()
+	}
+	return s.logger
+}
+
+// Metadata returns the metadata in the header of the map
+func (s *Simulation) Metadata() Metadata {
+	return s.metadata
+}
+
+// Repairs returns the changes made to the map when it was parsed with
+// WithRepair
+func (s *Simulation) Repairs() []RoadRepair {
+	return s.repairs
+}
+
+// Seed returns the seed of the random source used by the simulation
+func (s *Simulation) Seed() int64 {
+	return s.seed
+}
+
+// checkLimits returns SimStateMaxIterationsReached if the iteration cap has
+// been reached or if all aliens that are still able to move have used up
+// their moves, otherwise SimStateRunning
+func (s *Simulation) checkLimits() SimState {
+	if s.maxIterations > 0 && s.iteration >= s.maxIterations {
+		return SimStateMaxIterationsReached
+	}
+
+	if s.maxMoves <= 0 {
+		return SimStateRunning
+	}
+
+	for _, alien := range s.aliens {
+		if !alien.isDead() && !alien.isTrapped() && alien.hasMovesLeft(s.maxMoves) {
+			return SimStateRunning
+		}
+	}
+
+	return SimStateMaxIterationsReached
+}
+
 // SurvivedCities returns all cities that are not destroyed
 func (s *Simulation) SurvivedCities() map[string]*city {
 	survivedCities := make(map[string]*city)
@@ -1,10 +1,16 @@
 package simulation
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
 )
 
 func TestNewSimulation(t *testing.T) {
@@ -26,11 +32,59 @@ Qux south=Baz`
 	}
 }
 
+func TestNewSimulation_noCities(t *testing.T) {
+	_, err := NewSimulation(strings.NewReader(""), 2)
+	assert.EqualError(t, err, "no cities to place 2 aliens in")
+}
+
+func TestNewSimulation_mapAliens(t *testing.T) {
+	input := `@name Pair
+@aliens 3
+Foo north=Bar
+Bar south=Foo`
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens)
+	assert.NoError(t, err, "expected no error when creating simulation")
+	assert.Len(t, sim.aliens, 3, "expected the number of aliens from the map header")
+	assert.Equal(t, Metadata{Name: "Pair", Aliens: 3}, sim.Metadata())
+
+	sim, err = NewSimulation(strings.NewReader(input), 2)
+	assert.NoError(t, err, "expected no error when creating simulation")
+	assert.Len(t, sim.aliens, 2, "expected the given number of aliens to take precedence")
+
+	_, err = NewSimulation(strings.NewReader("Foo"), MapAliens)
+	assert.EqualError(t, err, "no number of aliens given and the map has no @aliens directive")
+}
+
+func TestNewSimulation_options(t *testing.T) {
+	input := `Foo north=Bar
+Bar south=Foo`
+
+	sim, err := NewSimulation(strings.NewReader(input), 2)
+	assert.NoError(t, err, "expected no error when creating simulation")
+	assert.Equal(t, DefaultMaxMoves, sim.maxMoves, "expected the default move cap")
+	assert.Equal(t, 0, sim.maxIterations, "expected no iteration cap by default")
+
+	sim, err = NewSimulation(strings.NewReader(input), 2, WithMaxIterations(10), WithMaxMoves(0))
+	assert.NoError(t, err, "expected no error when creating simulation")
+	assert.Equal(t, 0, sim.maxMoves, "expected the move cap to be disabled")
+	assert.Equal(t, 10, sim.maxIterations, "expected an iteration cap of 10")
+
+	sim, err = NewSimulation(strings.NewReader(input), 2, WithSeed(42))
+	assert.NoError(t, err, "expected no error when creating simulation")
+	assert.Equal(t, int64(42), sim.Seed(), "expected the simulation to report its seed")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard))
+	sim, err = NewSimulation(strings.NewReader(input), 2, WithLogger(logger))
+	assert.NoError(t, err, "expected no error when creating simulation")
+	assert.Same(t, logger, sim.log(), "expected the simulation to use the given logger")
+}
+
 func TestSimulation_Run(t *testing.T) {
 	tests := []struct {
 		name       string
 		simulation *Simulation
-		expected   simState
+		expected   SimState
 	}{
 		{
 			name: "all aliens are trapped",
@@ -100,6 +154,7 @@ func TestSimulation_Run(t *testing.T) {
 						"City2": city2,
 						"City3": city3,
 					},
+					rnd: rand.New(rand.NewSource(1)),
 				}
 			}(),
 			expected: SimStateOnlyOneAlienLeft,
@@ -144,10 +199,39 @@ func TestSimulation_Run(t *testing.T) {
 						"City3": city3,
 						"City4": city4,
 					},
+					rnd: rand.New(rand.NewSource(1)),
 				}
 			}(),
 			expected: SimStateAliveAliensDisconnected,
 		},
+		{
+			name: "all aliens have used up their moves",
+			simulation: func() *Simulation {
+				city1 := &city{name: "City1"}
+				city2 := &city{name: "City2"}
+				city1.neighbors = map[direction]*city{
+					north: city2,
+				}
+				city2.neighbors = map[direction]*city{
+					south: city1,
+				}
+
+				alien1 := &alien{name: 1, currentCity: city1, moves: 3}
+				alien2 := &alien{name: 2, currentCity: city2, moves: 3}
+				city1.visitingAliens = []*alien{alien1}
+				city2.visitingAliens = []*alien{alien2}
+
+				return &Simulation{
+					aliens: []*alien{alien1, alien2},
+					cities: map[string]*city{
+						"City1": city1,
+						"City2": city2,
+					},
+					maxMoves: 3,
+				}
+			}(),
+			expected: SimStateMaxIterationsReached,
+		},
 	}
 
 	for _, test := range tests {
@@ -159,6 +243,242 @@ func TestSimulation_Run(t *testing.T) {
 	}
 }
 
+func TestSimulation_Run_sameSeedSameResult(t *testing.T) {
+	input := `Fex east=Bar west=Jaz
+Bar west=Fex south=Zor east=Qux
+Zor north=Bar west=Baz
+Baz south=Jaz north=Qux east=Zor
+Jaz north=Baz east=Fex
+Qux north=Zox south=Baz west=Bar
+Zox south=Qux east=Xaz
+Xaz west=Zox north=Vex
+Vex south=Xaz`
+
+	run := func() (SimState, int, string) {
+		sim, err := NewSimulation(strings.NewReader(input), 4, WithSeed(7))
+		assert.NoError(t, err, "expected no error when creating simulation")
+
+		state, err := sim.Run()
+		assert.NoError(t, err, "expected no error when running simulation")
+
+		return state, sim.iteration, sim.CitiesToString(sim.SurvivedCities())
+	}
+
+	expectedState, expectedIterations, expectedCities := run()
+	for i := 0; i < 10; i++ {
+		state, iterations, cities := run()
+		assert.Equal(t, expectedState, state, "expected the same end state for the same seed")
+		assert.Equal(t, expectedIterations, iterations, "expected the same number of iterations for the same seed")
+		assert.Equal(t, expectedCities, cities, "expected the same surviving cities for the same seed")
+	}
+}
+
+func TestSimulation_RunContext_cancelled(t *testing.T) {
+	input := `Foo north=Bar
+Bar south=Foo`
+
+	sim, err := NewSimulation(strings.NewReader(input), 2, WithSeed(1))
+	assert.NoError(t, err, "expected no error when creating simulation")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	state, err := sim.RunContext(ctx)
+	assert.NoError(t, err, "expected no error when the simulation is interrupted")
+	assert.EqualValues(t, SimStateInterrupted, state, "expected simulation to be interrupted")
+	assert.Equal(t, 0, sim.iteration, "expected no iteration to run")
+	assert.Len(t, sim.SurvivedCities(), 2, "expected the world to be left untouched")
+	assert.Equal(t, SimStateInterrupted, sim.Result().State, "expected the result to report the interruption")
+}
+
+// newSettledSimulation returns a simulation of the map in input, seeded with
+// 1, in which alien i+1 has visited the cities in visited[i] and is in the
+// last of them. All aliens have used up their moves so the outcome doesn't
+// depend on the random source
+func newSettledSimulation(t *testing.T, input string, visited ...[]string) *Simulation {
+	placements := &strings.Builder{}
+	for i, cities := range visited {
+		fmt.Fprintf(placements, "alien %d %s\n", i+1, cities[len(cities)-1])
+	}
+
+	sim, err := NewSimulation(strings.NewReader(input), len(visited),
+		WithPlacementFile(writePlacementFile(t, placements.String())),
+		WithMaxMoves(1),
+		WithSeed(1),
+	)
+	require.NoError(t, err)
+	for i, a := range sim.aliens {
+		a.moves = 1
+		a.visited = visited[i]
+	}
+	return sim
+}
+
+func TestSimulation_Step(t *testing.T) {
+	sim := newSettledSimulation(t, "City1 north=City2\nCity2 south=City1",
+		[]string{"City1"},
+		[]string{"City2"},
+		[]string{"City2"},
+	)
+
+	result, err := sim.Step()
+	assert.NoError(t, err, "expected no error when stepping simulation")
+	assert.Equal(t, 1, result.Iteration, "expected the first iteration")
+	assert.Empty(t, result.Moves, "expected no moves")
+	assert.Equal(t, []Battle{{City: "City2", Aliens: []int{2, 3}, CityDestroyed: true}}, result.Battles)
+	assert.EqualValues(t, SimStateAllAliensDeadOrTrapped, result.State, "expected alien 1 to be trapped")
+	assert.True(t, sim.cities["City2"].isDestroyed(), "expected City2 to be destroyed")
+
+	// once the simulation has ended, stepping doesn't change anything
+	ended, err := sim.Step()
+	assert.NoError(t, err, "expected no error when stepping an ended simulation")
+	assert.Equal(t, result.State, ended.State, "expected the end state to be kept")
+	assert.Equal(t, result.Iteration, ended.Iteration, "expected no new iteration")
+	assert.Empty(t, ended.Moves, "expected no moves after the simulation ended")
+}
+
+func TestSimulation_checkEndState_oneWayRoads(t *testing.T) {
+	// City1 -> City2 <- City3 -> City4
+	city1 := &city{name: "City1", oneWay: map[direction]bool{east: true}}
+	city2 := &city{name: "City2"}
+	city3 := &city{name: "City3", oneWay: map[direction]bool{west: true, east: true}}
+	city4 := &city{name: "City4"}
+	city1.neighbors = map[direction]*city{east: city2}
+	city3.neighbors = map[direction]*city{west: city2, east: city4}
+
+	testCases := []struct {
+		name     string
+		cities   []*city
+		expected SimState
+	}{
+		{"alien can follow a one-way road to the other", []*city{city1, city2}, SimStateRunning},
+		{"aliens can meet in a third city", []*city{city1, city3}, SimStateRunning},
+		{"aliens are at dead ends", []*city{city2, city4}, SimStateAllAliensDeadOrTrapped},
+		{"alien can't go back against a one-way road", []*city{city1, city4}, SimStateAliveAliensDisconnected},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sim := &Simulation{
+				aliens: []*alien{
+					{name: 1, currentCity: tc.cities[0]},
+					{name: 2, currentCity: tc.cities[1]},
+				},
+			}
+			assert.Equal(t, tc.expected, sim.checkEndState())
+		})
+	}
+}
+
+func TestSimulation_Step_oneWayMove(t *testing.T) {
+	// A <-> B -> C, B -> D <-> E. Alien 1 moves from A to B and on to the dead
+	// end C while alien 2 stays in D, after which they can't meet anymore
+	t.Cleanup(func() {
+		movementStrategies.unregister("test-one-way-east")
+		movementStrategies.unregister("test-one-way-stay")
+	})
+	require.NoError(t, RegisterMovementStrategy("test-one-way-east", MovementFunc(func(Surroundings, *rand.Rand) string { return "east" })))
+	require.NoError(t, RegisterMovementStrategy("test-one-way-stay", MovementFunc(func(Surroundings, *rand.Rand) string { return Stay })))
+	input := `{
+  "cities": [
+    {"name": "A", "roads": [{"direction": "east", "city": "B"}]},
+    {"name": "B", "roads": [{"direction": "west", "city": "A"}, {"direction": "east", "city": "C", "one_way": true}, {"direction": "south", "city": "D", "one_way": true}]},
+    {"name": "C", "roads": []},
+    {"name": "D", "roads": [{"direction": "east", "city": "E"}]},
+    {"name": "E", "roads": [{"direction": "west", "city": "D"}]}
+  ],
+  "placements": [{"alien": 1, "city": "A"}, {"alien": 2, "city": "D"}]
+}`
+
+	sim, err := NewSimulation(strings.NewReader(input), MapAliens,
+		WithAlienMovementStrategy(1, "test-one-way-east"),
+		WithAlienMovementStrategy(2, "test-one-way-stay"),
+	)
+	require.NoError(t, err)
+
+	result, err := sim.Step()
+	require.NoError(t, err)
+	assert.Equal(t, []Move{{Alien: 1, From: "A", To: "B"}}, result.Moves)
+	assert.Equal(t, SimStateRunning, result.State, "expected the aliens to be able to meet")
+
+	result, err = sim.Step()
+	require.NoError(t, err)
+	assert.Equal(t, []Move{{Alien: 1, From: "B", To: "C"}}, result.Moves)
+	assert.Equal(t, SimStateAliveAliensDisconnected, result.State, "expected the aliens to be unable to meet after the move")
+}
+
+func TestSimulation_Run_staying(t *testing.T) {
+	// aliens that keep staying use up their moves
+	t.Cleanup(func() { movementStrategies.unregister("test-stay") })
+	require.NoError(t, RegisterMovementStrategy("test-stay", MovementFunc(func(Surroundings, *rand.Rand) string { return Stay })))
+	input := strings.Replace(chainMap, "%s", `{"alien": 1, "city": "A"}, {"alien": 2, "city": "D"}`, 1)
+
+	testCases := []struct {
+		name string
+		opts []Option
+	}{
+		{"in order", nil},
+		{"simultaneous", []Option{WithSimultaneousMoves(CrossingBattle)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]Option{WithMovementStrategy("test-stay"), WithMaxMoves(3)}, tc.opts...)
+			sim, err := NewSimulation(strings.NewReader(input), MapAliens, opts...)
+			require.NoError(t, err)
+
+			state, err := sim.Run()
+			require.NoError(t, err)
+			assert.Equal(t, SimStateMaxIterationsReached, state)
+
+			result := sim.Result()
+			assert.Equal(t, 3, result.Iterations, "expected the aliens to use up their moves by staying")
+			assert.Zero(t, result.Aliens[0].Moves, "expected staying to not be reported as a move")
+		})
+	}
+}
+
+func TestSimulation_checkLimits(t *testing.T) {
+	city1 := &city{name: "City1"}
+	city2 := &city{name: "City2"}
+	city1.neighbors = map[direction]*city{
+		north: city2,
+	}
+	city2.neighbors = map[direction]*city{
+		south: city1,
+	}
+
+	tests := []struct {
+		name          string
+		iteration     int
+		maxIterations int
+		maxMoves      int
+		moves         int
+		expected      SimState
+	}{
+		{"no limits", 100, 0, 0, 100, SimStateRunning},
+		{"below iteration cap", 4, 5, 0, 0, SimStateRunning},
+		{"iteration cap reached", 5, 5, 0, 0, SimStateMaxIterationsReached},
+		{"below move cap", 10, 0, 5, 4, SimStateRunning},
+		{"move cap reached", 10, 0, 5, 5, SimStateMaxIterationsReached},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sim := &Simulation{
+				aliens: []*alien{
+					{name: 1, currentCity: city1, moves: test.moves},
+					{name: 2, currentCity: city2, moves: test.moves},
+				},
+				iteration:     test.iteration,
+				maxIterations: test.maxIterations,
+				maxMoves:      test.maxMoves,
+			}
+			assert.Equal(t, test.expected, sim.checkLimits())
+		})
+	}
+}
+
 func TestSimulation_SurvivedCities(t *testing.T) {
 	sim := &Simulation{
 		cities: map[string]*city{
@@ -0,0 +1,155 @@
+package simulation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// DefaultVocabulary is the name of the vocabulary used when none is set, it
+// contains the four compass directions
+const DefaultVocabulary = "compass"
+
+// vocabulary is a set of directions roads can have, each direction has an
+// opposite which is the direction of the road back
+type vocabulary struct {
+	name      string
+	opposites map[direction]direction
+}
+
+// opposite returns the opposite of d, or an empty direction if d is not part
+// of the vocabulary
+func (v *vocabulary) opposite(d direction) direction {
+	return v.opposites[d]
+}
+
+func (v *vocabulary) isValid(d direction) bool {
+	_, ok := v.opposites[d]
+	return ok
+}
+
+var (
+	vocabularies = newRegistry[*vocabulary]("vocabulary")
+	// compass is the default vocabulary, it is used by cities without a
+	// vocabulary
+	compass = mustRegisterVocabulary(DefaultVocabulary, map[string]string{
+		"north": "south",
+		"east":  "west",
+	})
+)
+
+func init() {
+	mustRegisterVocabulary("compass8", map[string]string{
+		"north":     "south",
+		"east":      "west",
+		"northeast": "southwest",
+		"northwest": "southeast",
+	})
+	// hexagons with flat tops, there is no road to the east or west
+	mustRegisterVocabulary("hex", map[string]string{
+		"north":     "south",
+		"northeast": "southwest",
+		"northwest": "southeast",
+	})
+	// the compass directions with up and down, for maps with several levels
+	mustRegisterVocabulary("compass3d", map[string]string{
+		"north": "south",
+		"east":  "west",
+		"up":    "down",
+	})
+}
+
+// RegisterVocabulary registers a set of directions under name, so it can be
+// used with WithVocabulary. opposites maps directions to their opposite, the
+// reverse mapping is added automatically. A direction may be its own
+// opposite
+func RegisterVocabulary(name string, opposites map[string]string) error {
+	if name == "" {
+		return fmt.Errorf("vocabulary name must not be empty")
+	}
+	if len(opposites) == 0 {
+		return fmt.Errorf("vocabulary '%s' has no directions", name)
+	}
+
+	v := &vocabulary{name: name, opposites: make(map[direction]direction, len(opposites)*2)}
+	for _, d := range sortedKeys(opposites) {
+		o := opposites[d]
+		for _, s := range []string{d, o} {
+			if !isValidDirectionName(s) {
+				return fmt.Errorf("invalid direction '%s' in vocabulary '%s'", s, name)
+			}
+		}
+
+		for _, pair := range [][2]direction{{direction(d), direction(o)}, {direction(o), direction(d)}} {
+			from, to := pair[0], pair[1]
+			if existing, ok := v.opposites[from]; ok && existing != to {
+				return fmt.Errorf(
+					"direction '%s' in vocabulary '%s' has two opposites: '%s' and '%s'",
+					from, name, existing, to,
+				)
+			}
+			v.opposites[from] = to
+		}
+	}
+
+	return vocabularies.register(name, v)
+}
+
+// Vocabularies returns the names of all registered vocabularies in sorted
+// order
+func Vocabularies() []string {
+	return vocabularies.names()
+}
+
+// VocabularyDirections returns the directions of the vocabulary in sorted
+// order, ok is false if no vocabulary with the name is registered
+func VocabularyDirections(name string) (directions []string, ok bool) {
+	v, err := lookupVocabulary(name)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, d := range maps.Keys(v.opposites) {
+		directions = append(directions, string(d))
+	}
+	slices.Sort(directions)
+	return directions, true
+}
+
+// lookupVocabulary returns the vocabulary registered under name, the default
+// vocabulary is returned if name is empty
+func lookupVocabulary(name string) (*vocabulary, error) {
+	if name == "" {
+		return compass, nil
+	}
+
+	return vocabularies.lookup(name)
+}
+
+func mustRegisterVocabulary(name string, opposites map[string]string) *vocabulary {
+	if err := RegisterVocabulary(name, opposites); err != nil {
+		panic(err)
+	}
+
+	v, _ := vocabularies.lookup(name)
+	return v
+}
+
+// isValidDirectionName returns true if s can be used as a direction in the
+// map format
+func isValidDirectionName(s string) bool {
+	return s != "" &&
+		strings.IndexFunc(s, unicode.IsSpace) < 0 &&
+		!strings.Contains(s, twoWaySeparator) &&
+		!strings.Contains(s, oneWaySeparator)
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]string) []string {
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,153 @@
+package simulation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVocabulary_opposite(t *testing.T) {
+	tests := []struct {
+		input    direction
+		expected direction
+	}{
+		{north, south},
+		{south, north},
+		{east, west},
+		{west, east},
+		{direction("invalid"), direction("")},
+	}
+
+	for _, test := range tests {
+		actual := compass.opposite(test.input)
+		assert.Equal(t, test.expected, actual)
+	}
+}
+
+func TestVocabulary_isValid(t *testing.T) {
+	tests := []struct {
+		input    direction
+		expected bool
+	}{
+		{north, true},
+		{south, true},
+		{east, true},
+		{west, true},
+		{"", false},
+		{"foo", false},
+		{"northeast", false},
+	}
+
+	for _, test := range tests {
+		actual := compass.isValid(test.input)
+		assert.Equal(t, test.expected, actual)
+	}
+}
+
+func TestVocabularies(t *testing.T) {
+	assert.Subset(t, Vocabularies(), []string{"compass", "compass3d", "compass8", "hex"})
+
+	directions, ok := VocabularyDirections("hex")
+	assert.True(t, ok, "expected hex vocabulary to be registered")
+	assert.Equal(t, []string{"north", "northeast", "northwest", "south", "southeast", "southwest"}, directions)
+
+	directions, ok = VocabularyDirections("")
+	assert.True(t, ok, "expected the default vocabulary for an empty name")
+	assert.Equal(t, []string{"east", "north", "south", "west"}, directions)
+
+	_, ok = VocabularyDirections("unknown")
+	assert.False(t, ok)
+}
+
+func TestRegisterVocabulary(t *testing.T) {
+	t.Cleanup(func() { vocabularies.unregister("test-ring") })
+	err := RegisterVocabulary("test-ring", map[string]string{
+		"clockwise": "counterclockwise",
+		"inward":    "inward",
+	})
+	assert.NoError(t, err, "expected vocabulary to be registered")
+
+	v, err := lookupVocabulary("test-ring")
+	assert.NoError(t, err)
+	assert.Equal(t, direction("clockwise"), v.opposite("counterclockwise"))
+	assert.Equal(t, direction("inward"), v.opposite("inward"))
+
+	testCases := []struct {
+		name          string
+		vocabulary    string
+		opposites     map[string]string
+		expectedError string
+	}{
+		{"empty name", "", map[string]string{"a": "b"}, "vocabulary name must not be empty"},
+		{"no directions", "test-empty", nil, "vocabulary 'test-empty' has no directions"},
+		{"already registered", "test-ring", map[string]string{"a": "b"}, "vocabulary 'test-ring' is already registered"},
+		{"invalid direction", "test-invalid", map[string]string{"a=b": "c"}, "invalid direction 'a=b' in vocabulary 'test-invalid'"},
+		{
+			"two opposites",
+			"test-conflict",
+			map[string]string{"a": "b", "c": "b"},
+			"direction 'b' in vocabulary 'test-conflict' has two opposites: 'a' and 'c'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := RegisterVocabulary(tc.vocabulary, tc.opposites)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestParseInput_vocabulary(t *testing.T) {
+	input := `Foo northeast=Bar up=Baz
+Bar southwest=Foo
+Baz down=Foo`
+
+	_, err := parseInput(strings.NewReader(input), parseConfig{})
+	assert.EqualError(t, err, "invalid direction 'northeast' for city 'Foo'", "expected the default vocabulary to reject northeast")
+
+	_, err = parseInput(strings.NewReader(input), parseConfig{vocabulary: "compass8"})
+	assert.EqualError(t, err, "invalid direction 'up' for city 'Foo'")
+
+	_, err = parseInput(strings.NewReader(input), parseConfig{vocabulary: "unknown"})
+	assert.EqualError(t, err, "unknown vocabulary 'unknown'")
+
+	t.Cleanup(func() { vocabularies.unregister("test-compass8-3d") })
+	err = RegisterVocabulary("test-compass8-3d", map[string]string{
+		"north":     "south",
+		"east":      "west",
+		"northeast": "southwest",
+		"northwest": "southeast",
+		"up":        "down",
+	})
+	assert.NoError(t, err)
+
+	m, err := parseInput(strings.NewReader(input), parseConfig{vocabulary: "test-compass8-3d"})
+	assert.NoError(t, err, "expected the map to be valid in the custom vocabulary")
+
+	foo := m.cities["Foo"]
+	assert.Equal(t, "Foo northeast=Bar up=Baz", foo.string(), "expected roads in sorted order")
+	assert.NoError(t, foo.destroy(), "expected roads to be removed using the vocabulary's opposites")
+	assert.Empty(t, m.cities["Bar"].neighbors)
+	assert.Empty(t, m.cities["Baz"].neighbors)
+}
+
+func TestNewSimulation_withVocabulary(t *testing.T) {
+	input := "A north=B northeast=C\nB south=A\nC southwest=A"
+
+	_, err := NewSimulation(strings.NewReader(input), 2)
+	assert.Error(t, err, "expected the map to be rejected with the default vocabulary")
+
+	sim, err := NewSimulation(strings.NewReader(input), 2, WithVocabulary("hex"), WithSeed(1))
+	assert.NoError(t, err)
+	_, err = sim.Run()
+	assert.NoError(t, err, "expected the simulation to run with the hex vocabulary")
+
+	repairs, diagnostics, err := RepairMap(strings.NewReader("A up=B\nB"), nil, WithVocabulary("compass3d"))
+	assert.NoError(t, err)
+	assert.Empty(t, diagnostics)
+	assert.Equal(t, []RoadRepair{
+		{Action: RoadAdded, Line: 1, Column: 3, City: "B", Direction: "down", Neighbor: "A", ReverseDirection: "up"},
+	}, repairs)
+}